@@ -0,0 +1,64 @@
+package queue
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// AroundFunc wraps a run of a queue (see Around()). It gets the context of the run and must
+// call run at most once with that context or a context derived from it. run runs the calls of
+// the queue and returns the first CallPanic of them as panicked (even if it was handled by an
+// error handler) and the error of the run. The error returned by the AroundFunc is the error of
+// the run of the queue.
+type AroundFunc func(ctx context.Context, run func(context.Context) (panicked, err error)) error
+
+// Around sets a function that wraps every run of q, also when q is nested in another queue,
+// e.g. to run the calls of q in a transaction. If fn is nil, the function is removed.
+// It may be chained.
+//
+// If fn does not call run, the calls of q are not run and the queue returns no values.
+func (q *Queue) Around(fn AroundFunc) *Queue {
+	q.around = fn
+	return q
+}
+
+type panickedKey struct{}
+
+// firstPanic is the first CallPanic of a run wrapped by an AroundFunc
+type firstPanic struct {
+	mu  sync.Mutex
+	err error
+}
+
+// notePanic notes the CallPanic err for the innermost AroundFunc of ctx
+func notePanic(ctx context.Context, err error) {
+	p, ok := ctx.Value(panickedKey{}).(*firstPanic)
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+}
+
+// runAround runs the calls of q with the given values, wrapped by the AroundFunc of q
+func (q *Queue) runAround(ctx context.Context, vals []reflect.Value) (returns []reflect.Value, err error) {
+	if q.around == nil {
+		return q.runCalls(ctx, vals)
+	}
+	err = q.around(ctx, func(ctx context.Context) (error, error) {
+		p := &firstPanic{}
+		var runErr error
+		returns, runErr = q.runCalls(context.WithValue(ctx, panickedKey{}, p), vals)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.err, runErr
+	})
+	if err != nil {
+		returns = nil
+	}
+	return
+}
@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type aroundKey struct{}
+
+func TestAround(t *testing.T) {
+	var log []string
+	around := func(ctx context.Context, run func(context.Context) (error, error)) error {
+		log = append(log, "before")
+		panicked, err := run(context.WithValue(ctx, aroundKey{}, "value"))
+		log = append(log, "after")
+		if panicked != nil {
+			log = append(log, "panicked")
+		}
+		return err
+	}
+	fromCtx := func(ctx context.Context) { log = append(log, ctx.Value(aroundKey{}).(string)) }
+	errFail := errors.New("fail")
+	fail := func() error { return errFail }
+	panics := func() { panic("oops") }
+
+	tests := []struct {
+		q        *Queue
+		err      error
+		expected []string
+	}{
+		{New().Around(around).Add(fromCtx, CTX),
+			nil, []string{"before", "value", "after"}},
+		{New().Around(around).Add(fail),
+			errFail, []string{"before", "after"}},
+		{New().Around(around).OnError(IGNORE).Add(panics).Add(fromCtx, CTX),
+			nil, []string{"before", "value", "after", "panicked"}},
+		// nested
+		{New().Sub(New().Around(around).Add(fromCtx, CTX)),
+			nil, []string{"before", "value", "after"}},
+	}
+
+	for i, test := range tests {
+		log = nil
+		if err := test.q.Run(); err != test.err {
+			t.Errorf("tests[%d]: expected error %v, but got %v", i, test.err, err)
+		}
+		if !reflect.DeepEqual(log, test.expected) {
+			t.Errorf("tests[%d]: expected %#v, but got %#v", i, test.expected, log)
+		}
+	}
+
+	// the calls are not run, if run is not called
+	called := false
+	errSkip := errors.New("skip")
+	q := New().Around(func(context.Context, func(context.Context) (error, error)) error { return errSkip }).
+		Add(func() { called = true })
+	if err := q.Run(); err != errSkip || called {
+		t.Errorf("expected %v without call, but got %v, called: %v", errSkip, err, called)
+	}
+}
@@ -0,0 +1,200 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// AutoClose lets the runs of q close the values that are piped or passed via Call(), Run(),
+// Fallback() or Race() and implement io.Closer or can be closed by one of the given closers,
+// so that e.g. an *os.File does not need an explicit tee that closes it and is closed even if
+// the run stops early. It may be chained.
+//
+// A closer is a func(T) or func(T) error that closes values assignable to T, e.g.
+// func(r *http.Response) error { return r.Body.Close() }. Closers are tried in the given
+// order, before io.Closer. AutoClose panics if a closer is invalid.
+//
+// A value is closed as soon as it is no longer reachable, i.e.
+//
+//   - a piped value, when the next call or nested queue returns without passing it on (values
+//     passed into a nested queue are closed by the queue they come from)
+//   - a value passed as argument, when it is not returned by the call that gets it
+//   - a value returned by a tee, right after the tee
+//
+// and every value that is still open, when the run ends, including the values returned by
+// RunWithInput(). Values passed to RunWithInput() are never closed. Errors of closing are
+// passed as CloseError to the error handler of the queue.
+//
+// Like the debugger, only the AutoClose of the queue that is run counts, but it applies to
+// nested queues as well.
+func (q *Queue) AutoClose(closers ...interface{}) *Queue {
+	q.autoClose = true
+	q.closers = nil
+	for _, closer := range closers {
+		fn := reflect.ValueOf(closer)
+		if fn.Kind() != reflect.Func || fn.Type().NumIn() != 1 || fn.Type().IsVariadic() || fn.Type().NumOut() > 1 ||
+			(fn.Type().NumOut() == 1 && fn.Type().Out(0) != errorType) {
+			panic(fmt.Sprintf("invalid closer %T: must be func(T) or func(T) error", closer))
+		}
+		q.closers = append(q.closers, fn)
+	}
+	return q
+}
+
+var closerType = reflect.TypeOf((*io.Closer)(nil)).Elem()
+
+type autoCloseKey struct{}
+
+// autoCloser tracks the values of a run that are closed automatically
+type autoCloser struct {
+	closers []reflect.Value
+
+	mu   sync.Mutex
+	open []reflect.Value
+}
+
+// enterAutoClose returns the context for a run of q that closes values automatically, if q has
+// AutoClose and the run does not already close values. The returned autoCloser is nil, unless
+// it is created for the run of q.
+func (q *Queue) enterAutoClose(ctx context.Context) (context.Context, *autoCloser) {
+	if !q.autoClose || runAutoCloser(ctx) != nil {
+		return ctx, nil
+	}
+	a := &autoCloser{closers: q.closers}
+	return context.WithValue(ctx, autoCloseKey{}, a), a
+}
+
+// runAutoCloser returns the autoCloser of the run of the given context, nil if there is none
+func runAutoCloser(ctx context.Context) *autoCloser {
+	a, _ := ctx.Value(autoCloseKey{}).(*autoCloser)
+	return a
+}
+
+// closer returns the function that closes v, nil if v can't be closed
+func (a *autoCloser) closer(v reflect.Value) func() error {
+	if !v.IsValid() || (isNilable(v) && v.IsNil()) {
+		return nil
+	}
+	for _, fn := range a.closers {
+		if v.Type().AssignableTo(fn.Type().In(0)) {
+			fn := fn
+			return func() error {
+				if returns := fn.Call([]reflect.Value{v}); len(returns) == 1 && !returns[0].IsNil() {
+					return returns[0].Interface().(error)
+				}
+				return nil
+			}
+		}
+	}
+	if v.Type().Implements(closerType) {
+		return v.Interface().(io.Closer).Close
+	}
+	return nil
+}
+
+// track starts tracking the values of vals that can be closed and are not in except
+func (a *autoCloser) track(vals, except []reflect.Value) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, v := range vals {
+		if a.closer(v) != nil && indexOf(except, v) < 0 && indexOf(a.open, v) < 0 {
+			a.open = append(a.open, v)
+		}
+	}
+}
+
+// release closes the tracked values of dropped that are not in alive
+func (a *autoCloser) release(dropped, alive []reflect.Value) (errs []error) {
+	if a == nil {
+		return nil
+	}
+	for _, v := range dropped {
+		// only values that can be closed may be tracked
+		if a.closer(v) != nil && indexOf(alive, v) < 0 {
+			if err := a.close(v); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return
+}
+
+// closeAll closes all tracked values
+func (a *autoCloser) closeAll() (errs []error) {
+	a.mu.Lock()
+	open := a.open
+	a.mu.Unlock()
+	return a.release(open, nil)
+}
+
+// close closes v, if it is tracked, and stops tracking it
+func (a *autoCloser) close(v reflect.Value) (err error) {
+	a.mu.Lock()
+	i := indexOf(a.open, v)
+	if i >= 0 {
+		a.open = append(a.open[:i], a.open[i+1:]...)
+	}
+	a.mu.Unlock()
+	if i < 0 {
+		return nil
+	}
+
+	defer func() {
+		if e := recover(); e != nil {
+			err = CloseError{Type: v.Type().String(), Err: fmt.Errorf("panic: %v", e)}
+		}
+	}()
+	if err = a.closer(v)(); err != nil {
+		err = CloseError{Type: v.Type().String(), Err: err}
+	}
+	return
+}
+
+// indexOf returns the index of the value identical to v in vals, -1 if there is none
+func indexOf(vals []reflect.Value, v reflect.Value) int {
+	if !isComparable(v) {
+		return -1
+	}
+	for i, w := range vals {
+		if isComparable(w) && w.Type() == v.Type() && w.Interface() == v.Interface() {
+			return i
+		}
+	}
+	return -1
+}
+
+// isComparable reports, if v can be compared with ==, which depends on the dynamic type
+// of v for interfaces, e.g. an interface{} holding a slice can't
+func isComparable(v reflect.Value) bool {
+	if !v.IsValid() || !v.Type().Comparable() {
+		return false
+	}
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		return reflect.TypeOf(v.Interface()).Comparable()
+	}
+	return true
+}
+
+// concat returns the values of a and b in a new slice
+func concat(a, b []reflect.Value) []reflect.Value {
+	return append(append([]reflect.Value(nil), a...), b...)
+}
+
+// handleCloseErrors passes the errors of closing to errHandler and returns the first error
+// that is not handled
+func (q *Queue) handleCloseErrors(errHandler ErrHandler, errs []error) error {
+	for _, err := range errs {
+		err2 := errHandler.HandleError(err)
+		q.logDebug("[E] %T(%#v) => %#v", errHandler, err, err2)
+		if err2 != nil {
+			return err2
+		}
+	}
+	return nil
+}
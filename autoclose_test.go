@@ -0,0 +1,156 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// resource records when it is closed in the log
+type resource struct {
+	name     string
+	log      *[]string
+	closeErr error
+}
+
+func (r *resource) Close() error {
+	*r.log = append(*r.log, "close "+r.name)
+	return r.closeErr
+}
+
+// handle can't be closed by itself
+type handle struct {
+	name string
+	log  *[]string
+}
+
+func TestAutoClose(t *testing.T) {
+	var log []string
+	open := func(name string) *resource { return &resource{name: name, log: &log} }
+	use := func(r *resource) { log = append(log, "use "+r.name) }
+	pass := func(r *resource) *resource { return r }
+	step := func(name string) func() { return func() { log = append(log, name) } }
+	fail := func() error { return errors.New("fail") }
+
+	tests := []struct {
+		q        *Queue
+		expected []string
+	}{
+		// closed when not piped on
+		{Add(open, "a").Add(use, PIPE).Add(step("done")),
+			[]string{"use a", "close a", "done"}},
+		// closed when the run ends
+		{Add(open, "a").Add(pass, PIPE).Add(use, PIPE),
+			[]string{"use a", "close a"}},
+		// closed when the run stops early
+		{Add(open, "a").Add(fail).Add(use, PIPE),
+			[]string{"close a"}},
+		// passed via Call()
+		{Add(use, Call(open, "a")).Add(step("done")),
+			[]string{"use a", "close a", "done"}},
+		// passed via Call() and returned
+		{Add(pass, Call(open, "a")).Add(use, PIPE).Add(step("done")),
+			[]string{"use a", "close a", "done"}},
+		// returned by a tee
+		{Add(step("start")).Tee(open, "a").Add(step("done")),
+			[]string{"start", "close a", "done"}},
+		// returned by a nested queue
+		{New().Sub(Add(open, "a")).Add(use, PIPE).Add(step("done")),
+			[]string{"use a", "close a", "done"}},
+		// closed by the nested queue
+		{New().Sub(Add(open, "a").Add(use, PIPE)).Add(step("done")),
+			[]string{"use a", "close a", "done"}},
+		// passed into a nested queue and closed by the parent
+		{Add(open, "a").Sub(Add(use, PIPE)).Add(step("done")),
+			[]string{"use a", "close a", "done"}},
+		// piped to a tee queue and closed when the next call replaces the piped values
+		{Add(open, "a").TeeAndRun(Add(use, PIPE)).Add(step("done")),
+			[]string{"use a", "done", "close a"}},
+	}
+
+	for i, test := range tests {
+		log = nil
+		test.q.AutoClose().Run()
+		if !reflect.DeepEqual(log, test.expected) {
+			t.Errorf("tests[%d]: expected %#v, but got %#v", i, test.expected, log)
+		}
+	}
+
+	// without AutoClose
+	log = nil
+	Add(open, "a").Add(use, PIPE).Run()
+	if !reflect.DeepEqual(log, []string{"use a"}) {
+		t.Errorf("without AutoClose nothing should be closed, but got %#v", log)
+	}
+
+	// values passed to the run are not closed
+	log = nil
+	vals, err := Add(pass, PIPE).AutoClose().RunWithInput(context.Background(), open("in"))
+	if err != nil || len(vals) != 1 || len(log) != 0 {
+		t.Errorf("input should be returned, but got %#v, %v, %#v", vals, err, log)
+	}
+}
+
+func TestAutoCloseClosers(t *testing.T) {
+	var log []string
+	open := func(name string) *handle { return &handle{name: name, log: &log} }
+	closeHandle := func(h *handle) { *h.log = append(*h.log, "close "+h.name) }
+
+	log = nil
+	Add(open, "a").Add(func() {}).AutoClose(closeHandle).Run()
+	if !reflect.DeepEqual(log, []string{"close a"}) {
+		t.Errorf("handle should be closed by the closer, but got %#v", log)
+	}
+
+	for _, closer := range []interface{}{1, func() {}, func(*handle) int { return 0 }} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("AutoClose(%T) should panic", closer)
+				}
+			}()
+			New().AutoClose(closer)
+		}()
+	}
+}
+
+func TestAutoCloseErrors(t *testing.T) {
+	var log []string
+	errClose := errors.New("close failed")
+	open := func() *resource { return &resource{name: "a", log: &log, closeErr: errClose} }
+	done := func() { log = append(log, "done") }
+
+	log = nil
+	err := Add(open).Add(done).AutoClose().Run()
+	ce, ok := err.(CloseError)
+	if !ok || ce.Err != errClose || ce.Type != "*queue.resource" {
+		t.Errorf("expecting CloseError, but got %#v", err)
+	}
+	if !reflect.DeepEqual(log, []string{"done", "close a"}) {
+		t.Errorf("the value should be closed after done, but got %#v", log)
+	}
+
+	var handled []error
+	log = nil
+	err = Add(open).Add(done).Add(done).AutoClose().OnError(ErrHandlerFunc(func(err error) error {
+		handled = append(handled, err)
+		return nil
+	})).Run()
+	if err != nil || len(handled) != 1 || !reflect.DeepEqual(log, []string{"done", "close a", "done"}) {
+		t.Errorf("close error should be handled, but got %v, %v, %#v", err, handled, log)
+	}
+}
+
+func TestAutoCloseUncomparable(t *testing.T) {
+	var got interface{}
+	err := New().AutoClose().
+		Add(func() interface{} { return []int{1} }).
+		Add(func(x interface{}) interface{} { return x }, PIPE).
+		Add(func(x interface{}) { got = x }, PIPE).
+		Run()
+
+	if err != nil || !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("expecting []int{1} to be piped, but got %#v, %v", got, err)
+	}
+}
@@ -6,6 +6,16 @@ type call struct {
 	function  reflect.Value
 	arguments []interface{}
 	name      string
+
+	// optional, handles the errors of the call instead of the error handler of the queue
+	errHandler ErrHandler
+
+	// optional, failure convention of the call instead of the one of the queue (see FailOn())
+	failure Failure
+
+	// queues that are run instead of the function (for TeeAndRun() etc.)
+	feeded []Queuer
+	feed   feedKind
 }
 
 type callrun []Queuer
@@ -26,6 +36,59 @@ func CallNamed(name string, function interface{}, arguments ...interface{}) *cal
 	}
 }
 
+// OnError sets the error handler of the call that is used instead of the error
+// handler of the queue (see OnErrorAt()).
+func (c *call) OnError(handler ErrHandler) *call {
+	c.errHandler = handler
+	return c
+}
+
+// errHandlerAt returns the error handler of the call with the given name inside the call c of q,
+// skipping the queues that are currently searched (see Queue.errHandlerAt())
+// (c itself, nested calls and queues) when run in a queue with the given error handler
+func (c *call) errHandlerAt(q *Queue, name string, errHandler ErrHandler, walking map[*Queue]bool) ErrHandler {
+	if c.function.Type() == queuersType {
+		for _, qe := range c.function.Interface().([]Queuer) {
+			if h := qe.Queue().errHandlerAt(name, errHandler, walking); h != nil {
+				return h
+			}
+		}
+		return nil
+	}
+	if c.name == name {
+		if h := q.stepErrHandler(c); h != nil {
+			return h
+		}
+		return errHandler
+	}
+	for _, qe := range c.feeded {
+		if h := qe.Queue().errHandlerAt(name, errHandler, walking); h != nil {
+			return h
+		}
+	}
+	for _, arg := range c.arguments {
+		var qs []Queuer
+		switch a := arg.(type) {
+		case *call:
+			if h := a.errHandlerAt(q, name, errHandler, walking); h != nil {
+				return h
+			}
+		case callrun:
+			qs = a
+		case callfallback:
+			qs = a
+		case callrace:
+			qs = a.queues
+		}
+		for _, qe := range qs {
+			if h := qe.Queue().errHandlerAt(name, errHandler, walking); h != nil {
+				return h
+			}
+		}
+	}
+	return nil
+}
+
 // returns the given value and "Sets" the value of a pipe
 func Value(i interface{}) interface{} { return i }
 
@@ -64,7 +127,7 @@ func Fallback(qs ...Queuer) callfallback { return callfallback(qs) }
 // to the call chain.
 //
 // The special argument PIPE is a placeholder for the return values of the previous call
-// in the chain (minus returned errors).
+// in the chain (minus returned errors). A nil argument becomes the zero value of its parameter.
 //
 // The number and type signature of the arguments and piped return values must
 // match with the receiving function.
@@ -97,3 +160,15 @@ func Add(function interface{}, arguments ...interface{}) *Queue {
 func AddNamed(name string, function interface{}, arguments ...interface{}) *Queue {
 	return New().AddNamed(name, function, arguments...)
 }
+
+// AddWithHandler behaves like Add, but sets the given error handler for the call.
+// Errors of the call are passed to it instead of the error handler of the queue
+// (see OnErrorAt()).
+func (q *Queue) AddWithHandler(handler ErrHandler, function interface{}, arguments ...interface{}) *Queue {
+	q.calls = append(q.calls, &call{
+		function:   reflect.ValueOf(function),
+		arguments:  arguments,
+		errHandler: handler,
+	})
+	return q
+}
@@ -3,6 +3,9 @@ package queue
 import (
 	"fmt"
 	"reflect"
+	"strconv"
+
+	"gopkg.in/go-on/queue.v2/internal/params"
 )
 
 // Check checks if the function signatures and argument types match and returns any errors
@@ -11,23 +14,34 @@ func (q *Queue) Check() (err error) {
 }
 
 func (q *Queue) checkAndReturn(piped []reflect.Type) (returns []reflect.Type, err error) {
-	for i, c := range q.calls {
+	return (&checker{}).queue(q, "", piped, nil)
+}
 
-		piped, err = q.validateFn(c, i, piped)
+// CheckAll works like Check, but does not stop at the first error. It returns all errors
+// as CheckErrors or nil, if there are none.
+//
+// After an error, the checking continues with the best guess for the returned types.
+func (q *Queue) CheckAll() error {
+	return q.checkAll(false)
+}
 
-		if err != nil {
-			return
-		}
+// CheckStrict works like CheckAll, but also reports problems as Warning that would not
+// break a run:
+//
+//   - returned values of a call that are not used by the next call or its tees
+//   - PIPE passed to a call when there are no piped values
+//   - tees that are added before any call and are therefore never run
+func (q *Queue) CheckStrict() error {
+	return q.checkAll(true)
+}
 
-		for j, tee := range q.tees[i] {
-			_, err = q.validateFn(tee, i*100+j, piped)
-			if err != nil {
-				return
-			}
-		}
+func (q *Queue) checkAll(strict bool) error {
+	ch := &checker{all: true, strict: strict}
+	ch.queue(q, "", nil, nil)
+	if len(ch.errs) == 0 {
+		return nil
 	}
-	returns = piped
-	return
+	return ch.errs
 }
 
 func (q *Queue) check(piped []reflect.Type) (err error) {
@@ -35,90 +49,177 @@ func (q *Queue) check(piped []reflect.Type) (err error) {
 	return
 }
 
-// validate the number of arguments
-func validateNums(fn reflect.Type, args []reflect.Type) (numIns int, numArgs int, diff int, err error) {
-	numIns = fn.NumIn()
-	numArgs = len(args)
-	diff = numArgs - numIns
-	// if number is equal, there is never an error in num
-	if diff == 0 {
-		return
-	}
-	// if number is not equal and function is not variadic,
-	// it is an error for sure
-	if !fn.IsVariadic() {
-		err = fmt.Errorf("func wants %d arguments, but gets %d",
-			numIns, numArgs)
-		return
+// StepPath identifies a call or tee inside a queue and its nested queues.
+//
+// It consists of segments separated by a slash. The first segment is the position of the call in the queue.
+// Further segments may be "teeN" (the N-th tee of the call), "argN" (the call passed as N-th argument),
+// "subN", "runN", "fallbackN", "raceN" or "teeQueueN" (the N-th queue passed via Sub(), Run(), Fallback(),
+// Race() or TeeAndRun()/TeeAndFallback()), followed by the position of a call inside that queue.
+//
+// E.g. "3/arg1/fallback0/2" is the call at position 2 of the first queue that is passed via Fallback()
+// as second argument to the call at position 3.
+type StepPath string
+
+func (p StepPath) child(segment string) StepPath {
+	if p == "" {
+		return StepPath(segment)
 	}
+	return p + "/" + StepPath(segment)
+}
 
-	// we are here, if the number is not equal and
-	// the function is variadic. There should not be to few
-	if diff < -1 {
-		err = fmt.Errorf("func wants at least %d arguments, but gets %d",
-			numIns, numArgs)
-		return
-	}
+func (p StepPath) childN(segment string, n int) StepPath {
+	return p.child(segment + strconv.Itoa(n))
+}
 
-	// in all other cases the number of arguments is ok
-	return
+// checker validates the calls of queues and infers the types of the piped values.
+// By default it stops at the first error.
+type checker struct {
+	// continue after errors with the best guess for the returned types
+	all bool
+
+	// also report warnings (see CheckStrict())
+	strict bool
+
+	// errors found so far, collected if all is true
+	errs CheckErrors
+
+	// receives the report of every validated call, if set
+	report func(r stepReport)
+
+	// queues that are currently validated with the path where they were entered, to detect cycles
+	walking map[*Queue]StepPath
+
+	// converter of the currently validated queue
+	conv *Converter
 }
 
-// validates the arguments
-func validateArgs(fn reflect.Type, args []reflect.Type) error {
-	numIns, _, diff, err := validateNums(fn, args)
+// stepReport is the result of the validation of a call, tee or queue
+type stepReport struct {
+	path       StepPath
+	kind       StepKind
+	c          *call
+	q          *Queue
+	args       []reflect.Type
+	sources    []ArgKind
+	conv       []*conversion
+	returns    []reflect.Type
+	errHandler ErrHandler
+	err        error
+
+	// begin is set for the report when starting the validation of a call or queue,
+	// the final report (without begin) follows after all reports of nested calls and queues
+	begin bool
+}
 
-	// error in number of inputs, stop here
-	if err != nil {
-		return err
-	}
-	// no inputs: no check required
-	if numIns == 0 {
-		return nil
+// problem registers the error err for the call at path and reports if the checker should stop
+func (ch *checker) problem(path StepPath, err error) (stop bool) {
+	ch.errs = append(ch.errs, CheckError{Path: path, Err: err})
+	return !ch.all
+}
+
+// warn registers a warning for the call c at path, if the checker is strict
+func (ch *checker) warn(path StepPath, c *call, i int, format string, a ...interface{}) {
+	if ch.strict {
+		ch.problem(path, Warning{Position: i, Name: c.name, Message: fmt.Sprintf(format, a...)})
 	}
+}
 
-	// check all ins of the function unless the
-	// function is variadic, then skip the last in
-	limit := numIns
-	if fn.IsVariadic() {
-		limit -= 1
+// queue validates the calls of q that are called with the given piped types and returns the types
+// returned by the last call. parent is the error handler of the parent queue, if q is nested
+func (ch *checker) queue(q *Queue, path StepPath, piped []reflect.Type, parent ErrHandler) (returns []reflect.Type, err error) {
+	errHandler := q.resolveErrHandler(parent)
+	input := piped
+	if ch.report != nil {
+		ch.report(stepReport{path: path, q: q, args: input, errHandler: errHandler, begin: true})
 	}
 
-	for i := 0; i < limit; i++ {
-		is := args[i]
-		should := fn.In(i)
-		if !is.AssignableTo(should) {
-			return fmt.Errorf("%d. argument is a %#v but should be a %#v", i+1, is.String(), should.String())
+	if start, ok := ch.walking[q]; ok {
+		cycErr := CycleError{Start: start, Path: path, Name: q.name}
+		if ch.report != nil {
+			ch.report(stepReport{path: path, q: q, args: input, errHandler: errHandler, err: cycErr})
+		}
+		if ch.problem(path, cycErr) {
+			return nil, cycErr
 		}
+		// the nested queue would never return, so there are no returned types
+		return nil, nil
 	}
-	// if is not variadic, we're done
-	if !fn.IsVariadic() {
-		return nil
+
+	if ch.walking == nil {
+		ch.walking = map[*Queue]StepPath{}
 	}
+	ch.walking[q] = path
+	defer delete(ch.walking, q)
 
-	// now func must be variadic and we need to check all the args
-	// that are defined by the variadic
-	should := fn.In(numIns - 1).Elem()
-	for i := 0; i < diff+1; i++ {
-		j := i + numIns - 1
-		is := args[j]
-		if !is.AssignableTo(should) {
-			return fmt.Errorf("%d. argument  is a %#v but should be a %#v", j+1, is.String(), should.String())
+	if q.converter != nil {
+		defer func(parent *Converter) { ch.conv = parent }(ch.conv)
+		ch.conv = q.converter
+	}
+
+	for j, tee := range q.tees[-1] {
+		ch.warn(path.childN("", -1).childN("tee", j), tee, -100+j, "tee is added before any call and never run")
+	}
+
+	for i, c := range q.calls {
+		p := path.childN("", i)
+		kind := StepCall
+		if c.function.Type() == queuersType {
+			kind = StepSub
+		}
+		piped, err = ch.step(q, c, kind, i, p, piped, errHandler)
+		if err != nil {
+			return
+		}
+
+		for j, tee := range q.tees[i] {
+			kind := StepTee
+			switch tee.feed {
+			case feedRun, feedCheckRun:
+				kind = StepTeeRun
+			case feedFallback, feedCheckFallback:
+				kind = StepTeeFallback
+			}
+			_, err = ch.step(q, tee, kind, i*100+j, p.childN("tee", j), piped, errHandler)
+			if err != nil {
+				return
+			}
+		}
+
+		if len(piped) > 0 && i+1 < len(q.calls) && !q.usesPiped(i) {
+			ch.warn(p, c, i, "returned values (%s) are not used", explainTypes(piped))
 		}
 	}
+	returns = piped
 
-	return nil
+	if ch.report != nil {
+		ch.report(stepReport{path: path, q: q, args: input, returns: returns, errHandler: errHandler})
+	}
+	return
 }
 
-// validateFn validates the function at position i in the queue
-func (q *Queue) validateFn(c *call, i int, piped []reflect.Type) (returns []reflect.Type, err error) {
-	// fn := q.calls[i]
-
-	if c.function.Type() == queuersType {
-		qs := c.function.Interface().([]Queuer)
+// step validates the call c of q at position i in the queue, that is called with the given
+// piped types and returns the types returned by the call
+func (ch *checker) step(q *Queue, c *call, kind StepKind, i int, path StepPath, piped []reflect.Type, errHandler ErrHandler) (returns []reflect.Type, err error) {
+	r := stepReport{path: path, kind: kind, c: c, q: q, errHandler: errHandler}
+	if h := q.stepErrHandler(c); h != nil {
+		r.errHandler = h
+	}
+	if ch.report != nil {
+		begin := r
+		begin.begin = true
+		ch.report(begin)
+	}
+	defer func() {
+		if ch.report != nil && err == nil {
+			r.returns = returns
+			ch.report(r)
+		}
+	}()
 
-		for _, qq := range qs {
-			piped, err = qq.Queue().checkAndReturn(piped)
+	if kind == StepSub {
+		r.args = piped
+		for k, qq := range c.function.Interface().([]Queuer) {
+			piped, err = ch.queue(qq.Queue(), path.childN("sub", k), piped, errHandler)
 			if err != nil {
 				return
 			}
@@ -127,71 +228,127 @@ func (q *Queue) validateFn(c *call, i int, piped []reflect.Type) (returns []refl
 		return
 	}
 
+	if kind == StepTeeRun || kind == StepTeeFallback {
+		r.args = piped
+		for k, qq := range c.feeded {
+			_, err = ch.queue(qq.Queue(), path.childN("teeQueue", k), piped, errHandler)
+			if err != nil {
+				return
+			}
+		}
+		return
+	}
+
 	if c.function.Kind() != reflect.Func {
 		invErr := InvalidFunc{}
 		invErr.ErrorMessage = fmt.Sprintf("%#v is no func", c.function.Type().String())
 		invErr.Position = i
 		invErr.Name = c.name
 		invErr.Type = c.function.Type().String()
-		err = invErr
 		if c.name == "" {
 			q.logPanic("[%d] %#v is no func", i, c.function.Type().String())
 
 		} else {
 			q.logPanic("[%d] %#v %#v is no func", i, c.name, c.function.Type().String())
 		}
+		r.err = invErr
+		if ch.problem(path, invErr) {
+			err = invErr
+			return
+		}
+		// best guess: the piped values are passed through
+		returns = piped
 		return
 	}
 
 	all := []reflect.Type{}
+	sources := []ArgKind{}
+	var branches []branch
+	add := func(kind ArgKind, types ...reflect.Type) {
+		all = append(all, types...)
+		for range types {
+			sources = append(sources, kind)
+		}
+	}
 
 	for j, p := range c.arguments {
 		switch a := p.(type) {
 		case pipe:
-			all = append(all, piped...)
+			if len(piped) == 0 {
+				ch.warn(path, c, i, "PIPE is used, but there are no piped values")
+			}
+			add(ArgPipe, piped...)
+		case ctxArg:
+			add(ArgContext, contextType)
 		case *call:
-			returns, err = q.validateFn(a, i*100+j*10, piped)
+			returns, err = ch.step(q, a, StepCall, i*100+j*10, path.childN("arg", j), piped, errHandler)
 			if err != nil {
 				return
 			}
-			all = append(all, returns...)
+			add(ArgCall, returns...)
 
 		case callrun:
 			returns = piped
-			for _, qe := range a {
-				returns, err = qe.Queue().checkAndReturn(returns)
+			for k, qe := range a {
+				returns, err = ch.queue(qe.Queue(), path.childN("arg", j).childN("run", k), returns, errHandler)
 				if err != nil {
 					return
 				}
 			}
 
-			all = append(all, returns...)
+			// the values of the last queue are passed
+			if len(a) > 0 {
+				branches = append(branches, branch{ArgRun, j, len(all), 0, len(a) - 1, [][]reflect.Type{returns}})
+			}
+			add(ArgRun, returns...)
 
-			// TODO: all returns should match with the input arguments of the function
-			// no idea how to check it in a reasonable way (without too much overhead)
 		case callfallback:
-			for _, qe := range a {
-				returns, err = qe.Queue().checkAndReturn(piped)
+			b := branch{ArgFallback, j, len(all), len(a) - 1, len(a) - 1, nil}
+			for k, qe := range a {
+				returns, err = ch.queue(qe.Queue(), path.childN("arg", j).childN("fallback", k), piped, errHandler)
+				if err != nil {
+					return
+				}
+				b.alternatives = append(b.alternatives, returns)
+			}
+
+			if len(b.alternatives) > 0 {
+				branches = append(branches, b)
+			}
+			add(ArgFallback, returns...)
+
+		case callrace:
+			b := branch{ArgRace, j, len(all), 0, 0, nil}
+			// without queues, the piped values are passed
+			returns = piped
+			for k, qe := range a.queues {
+				returns, err = ch.queue(qe.Queue(), path.childN("arg", j).childN("race", k), piped, errHandler)
 				if err != nil {
 					return
 				}
+				b.alternatives = append(b.alternatives, returns)
+			}
+			if len(b.alternatives) > 0 {
+				returns = b.alternatives[0]
+				branches = append(branches, b)
 			}
 
-			all = append(all, returns...)
+			add(ArgRace, returns...)
 
 		default:
-			all = append(all, reflect.TypeOf(p))
+			add(ArgLiteral, reflect.TypeOf(p))
 		}
 	}
 	ftype := c.function.Type()
 
-	for ia := range all {
-		if all[ia] == nil {
-			all[ia] = ftype.In(ia)
-		}
-	}
+	nilTypes(ftype, all)
+	r.args = all
+	r.sources = sources
 
-	err = validateArgs(ftype, all)
+	err = validateBranches(ftype, all, branches, ch.conv)
+	if err == nil {
+		r.conv = ch.conv.conversions(ftype, all)
+	}
 	if err != nil {
 		invErr := InvalidArgument{}
 		invErr.ErrorMessage = err.Error()
@@ -204,15 +361,123 @@ func (q *Queue) validateFn(c *call, i int, piped []reflect.Type) (returns []refl
 		} else {
 			q.logPanic("[%d] %#v %v Invalid arguments: %s", i, c.name, c.function.Type().String(), err)
 		}
-		return
+		r.err = invErr
+		if ch.problem(path, invErr) {
+			return
+		}
+		// best guess: the function returns as declared
+		err = nil
+	}
+
+	returns = returnTypes(ftype, q.failureOf(c))
+	return
+}
+
+// branch are the types returned by the queues of a Run(), Fallback() or Race() argument
+type branch struct {
+	kind ArgKind
+
+	// index of the argument
+	arg int
+
+	// index of the first returned type in the arguments of the receiving function
+	offset int
+
+	// index of the alternative whose types are in the arguments
+	used int
+
+	// index of the queue that returns the types (for error messages)
+	queue int
+
+	// the returned types of each alternative (Fallback, Race) or of the last queue (Run)
+	alternatives [][]reflect.Type
+}
+
+func (b branch) String() string {
+	return fmt.Sprintf("%s %d of the %d. argument", b.kind, b.queue, b.arg+1)
+}
+
+// validateBranches validates the arguments of the function of type fn like validateArgs,
+// but accepts arguments that can be converted by conv.
+// Every alternative of the given branches has to return values that are valid arguments.
+// Errors of arguments returned by a branch name the branch.
+func validateBranches(fn reflect.Type, args []reflect.Type, branches []branch, conv *Converter) error {
+	pos, err := validateArgsAt(fn, args, conv)
+	if err != nil {
+		for _, b := range branches {
+			n := len(b.alternatives[b.used])
+			if pos >= b.offset && pos < b.offset+n {
+				return fmt.Errorf("%s (returned by %s)", err, b)
+			}
+		}
+		return err
 	}
 
+	for _, b := range branches {
+		used := b.alternatives[b.used]
+		for k, alt := range b.alternatives {
+			if k == b.used {
+				continue
+			}
+			b.queue = k
+			if len(alt) != len(used) {
+				return fmt.Errorf("%s returns %d values, but should return %d", b, len(alt), len(used))
+			}
+			altArgs := make([]reflect.Type, len(args))
+			copy(altArgs, args)
+			for x, t := range alt {
+				if t != nil {
+					altArgs[b.offset+x] = t
+				}
+			}
+			if _, err := validateArgsAt(fn, altArgs, conv); err != nil {
+				return fmt.Errorf("%s (returned by %s)", err, b)
+			}
+		}
+	}
+	return nil
+}
+
+// usesPiped reports, if the next call or any tee of the call at position i gets the returned values
+func (q *Queue) usesPiped(i int) bool {
+	if q.calls[i+1].usesPiped() {
+		return true
+	}
+	for _, tee := range q.tees[i] {
+		if tee.usesPiped() {
+			return true
+		}
+	}
+	return false
+}
+
+// usesPiped reports, if the call gets the piped values
+func (c *call) usesPiped() bool {
+	if c.function.Type() == queuersType || c.feed != 0 {
+		return true
+	}
+	for _, arg := range c.arguments {
+		switch a := arg.(type) {
+		case pipe, callrun, callfallback, callrace:
+			return true
+		case *call:
+			if a.usesPiped() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// returnTypes returns the types of the values returned by a function of
+// type ftype, without a final value that reports failures with the convention f
+func returnTypes(ftype reflect.Type, f Failure) (returns []reflect.Type) {
 	num := ftype.NumOut()
 	if num == 0 {
 		return
 	}
 
-	if ftype.Out(num-1).String() == "error" {
+	if reportsFailure(ftype.Out(num-1), f) {
 		num = num - 1
 	}
 	returns = make([]reflect.Type, num)
@@ -223,6 +488,103 @@ func (q *Queue) validateFn(c *call, i int, piped []reflect.Type) (returns []refl
 	return
 }
 
+// nilTypes gives untyped nil arguments the type of their parameter, since they become the
+// zero value of the parameter in a run (see pipeFn())
+func nilTypes(fn reflect.Type, args []reflect.Type) {
+	for i := range args {
+		if args[i] != nil {
+			continue
+		}
+		args[i] = params.Type(fn, i)
+		if args[i] == nil {
+			// there is no such parameter, which validateNums() reports
+			args[i] = emptyInterfaceType
+		}
+	}
+}
+
+// validate the number of arguments
+func validateNums(fn reflect.Type, args []reflect.Type) (numIns int, numArgs int, diff int, err error) {
+	numIns = fn.NumIn()
+	numArgs = len(args)
+	diff = numArgs - numIns
+	// if number is equal, there is never an error in num
+	if diff == 0 {
+		return
+	}
+	// if number is not equal and function is not variadic,
+	// it is an error for sure
+	if !fn.IsVariadic() {
+		err = fmt.Errorf("func wants %d arguments, but gets %d",
+			numIns, numArgs)
+		return
+	}
+
+	// we are here, if the number is not equal and
+	// the function is variadic. There should not be to few
+	if diff < -1 {
+		err = fmt.Errorf("func wants at least %d arguments, but gets %d",
+			numIns, numArgs)
+		return
+	}
+
+	// in all other cases the number of arguments is ok
+	return
+}
+
+// validates the arguments
+func validateArgs(fn reflect.Type, args []reflect.Type) error {
+	_, err := validateArgsAt(fn, args, nil)
+	return err
+}
+
+// validateArgsAt validates the arguments, that may be converted by conv, and returns the index
+// of the invalid argument (-1 if the number of arguments is invalid)
+func validateArgsAt(fn reflect.Type, args []reflect.Type, conv *Converter) (int, error) {
+	numIns, _, diff, err := validateNums(fn, args)
+
+	// error in number of inputs, stop here
+	if err != nil {
+		return -1, err
+	}
+	// no inputs: no check required
+	if numIns == 0 {
+		return -1, nil
+	}
+
+	// check all ins of the function unless the
+	// function is variadic, then skip the last in
+	limit := numIns
+	if fn.IsVariadic() {
+		limit -= 1
+	}
+
+	for i := 0; i < limit; i++ {
+		is := args[i]
+		should := fn.In(i)
+		if !conv.assignable(is, should) {
+			return i, fmt.Errorf("%d. argument is a %#v but should be a %#v", i+1, is.String(), should.String())
+		}
+	}
+	// if is not variadic, we're done
+	if !fn.IsVariadic() {
+		return -1, nil
+	}
+
+	// now func must be variadic and we need to check all the args
+	// that are defined by the variadic
+	should := fn.In(numIns - 1).Elem()
+	for i := 0; i < diff+1; i++ {
+		j := i + numIns - 1
+		is := args[j]
+		if !conv.assignable(is, should) {
+			return j, fmt.Errorf("%d. argument  is a %#v but should be a %#v", j+1, is.String(), should.String())
+		}
+	}
+
+	return -1, nil
+}
+
 // CheckAndRun first runs Check() to see, if there are any type errors in the
 // function signatures or arguments and returns them. Without such errors,
 // it then calls Run()
@@ -237,50 +599,11 @@ func (q *Queue) CheckAndRun() (err error) {
 // TeeAndCheckAndFallback tees the given queues and in the run checks
 // them before running the Fallback method
 func (q *Queue) TeeAndCheckAndFallback(feededQs ...Queuer) *Queue {
-	fn := func(args ...interface{}) (err error) {
-		for _, qe := range feededQs {
-			err = qe.Queue().check(toTypes(args))
-			if err != nil {
-				return err
-			}
-
-		}
-
-		errHandler := q.defaultErrHandler()
-		for _, qe := range feededQs {
-			err = qe.Queue().run(toValues(args))
-			if err == nil {
-				break
-			}
-		}
-
-		if err != nil {
-			err2 := errHandler.HandleError(err)
-			q.logDebug("[E] %T(%#v) => %#v", errHandler, err, err2)
-			err = err2
-		}
-		return
-	}
-	q.Tee(fn, PIPE)
-	return q
+	return q.teeFeeded(feedCheckFallback, feededQs)
 }
 
 // TeeAndCheckAndRun tees the given queues and in the run checks
 // them before running the Run method
 func (q *Queue) TeeAndCheckAndRun(feededQs ...Queuer) *Queue {
-	fn := func(args ...interface{}) error {
-		for _, feeded := range feededQs {
-			err := feeded.Queue().check(toTypes(args))
-			if err != nil {
-				return err
-			}
-			err = feeded.Queue().run(toValues(args))
-			if err != nil {
-				return err
-			}
-		}
-		return nil
-	}
-	q.Tee(fn, PIPE)
-	return q
+	return q.teeFeeded(feedCheckRun, feededQs)
 }
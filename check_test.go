@@ -444,3 +444,179 @@ func TestWrongParamsNamed(t *testing.T) {
 	}
 
 }
+
+func TestCheckAll(t *testing.T) {
+	s := &S{}
+	err := New().
+		Add(read).
+		AddNamed("wrong", s.Set, PIPE).
+		Add(4).
+		Add(s.Set, Call(strconv.Atoi, 5)).
+		Add(appendString, Run(Add(s.Set, "x"))).
+		CheckAll()
+
+	errs, ok := err.(CheckErrors)
+	if !ok {
+		t.Fatalf("error should be CheckErrors, but is %T", err)
+	}
+
+	expected := []struct {
+		path StepPath
+		err  error
+	}{
+		{"1", InvalidArgument{}},
+		{"2", InvalidFunc{}},
+		{"3/arg0", InvalidArgument{}},
+		{"4/arg0/run0/0", InvalidArgument{}},
+	}
+
+	if len(errs) != len(expected) {
+		t.Fatalf("expecting %d errors, got %d: %s", len(expected), len(errs), errs)
+	}
+
+	for i, e := range expected {
+		if errs[i].Path != e.path {
+			t.Errorf("errs[%d] should have path %#v, but has %#v", i, e.path, errs[i].Path)
+		}
+		if reflect.TypeOf(errs[i].Err) != reflect.TypeOf(e.err) {
+			t.Errorf("errs[%d] should be %T, but is %T", i, e.err, errs[i].Err)
+		}
+	}
+
+	if errs[0].Err.(InvalidArgument).Name != "wrong" {
+		t.Errorf("errs[0] should be named 'wrong', but is: %#v", errs[0].Err)
+	}
+
+	if New().Add(read).Add(set, PIPE).CheckAll() != nil {
+		t.Errorf("valid queue should have no errors")
+	}
+}
+
+func TestCheckStrict(t *testing.T) {
+	q := New().
+		Tee(appendString, "never").
+		Add(read).
+		Add(Ok).
+		Add(appendString, PIPE).
+		Add(read).
+		Tee(appendString, PIPE).
+		Add(read)
+
+	if err := q.CheckAll(); err != nil {
+		t.Errorf("expecting no errors, but got: %s", err)
+	}
+
+	err := q.CheckStrict()
+	errs, ok := err.(CheckErrors)
+	if !ok {
+		t.Fatalf("error should be CheckErrors, but is %T", err)
+	}
+
+	expected := []StepPath{"-1/tee0", "0", "2"}
+
+	if len(errs) != len(expected) {
+		t.Fatalf("expecting %d warnings, got %d: %s", len(expected), len(errs), errs)
+	}
+
+	for i, path := range expected {
+		if errs[i].Path != path {
+			t.Errorf("errs[%d] should have path %#v, but has %#v", i, path, errs[i].Path)
+		}
+		if _, ok := errs[i].Err.(Warning); !ok {
+			t.Errorf("errs[%d] should be a Warning, but is %T", i, errs[i].Err)
+		}
+	}
+
+	if !strings.Contains(errs[1].Error(), "(string) are not used") {
+		t.Errorf("wrong warning: %s", errs[1])
+	}
+}
+
+func TestCheckFallbackAlternatives(t *testing.T) {
+	s := &S{}
+	tests := []struct {
+		q   *Queue
+		msg string
+	}{
+		// all alternatives fit
+		{Add(s.Set, Fallback(Add(Value, 1).Add(func(interface{}) int { return 1 }, PIPE), Add(multiInts).Add(func(a, b, c int) int { return a }, PIPE))), ""},
+
+		// first alternative does not fit
+		{Add(s.Set, Fallback(Add(read), Add(Value, 1).Add(func(interface{}) int { return 1 }, PIPE))), `1. argument is a "string" but should be a "int" (returned by Fallback 0 of the 1. argument)`},
+
+		// last alternative does not fit
+		{Add(s.Set, Fallback(Add(Value, 1).Add(func(interface{}) int { return 1 }, PIPE), Add(read))), `1. argument is a "string" but should be a "int" (returned by Fallback 1 of the 1. argument)`},
+
+		// wrong number of values
+		{Add(addIntsToString, "x", Fallback(Add(multiInts), Add(Value, 2).Add(func(interface{}) int { return 1 }, PIPE))), `Fallback 0 of the 2. argument returns 3 values, but should return 1`},
+
+		// race
+		{Add(s.Set, Race(Add(Value, 1).Add(func(interface{}) int { return 1 }, PIPE), Add(read))), `(returned by Race 1 of the 1. argument)`},
+
+		// run chain
+		{Add(set, "x").Add(s.Set, Run(Add(read), Add(appendString, PIPE))), `func wants 1 arguments, but gets 0`},
+		{Add(set, "x").Add(s.Set, Run(Add(Ok), Add(read))), `1. argument is a "string" but should be a "int" (returned by Run 1 of the 1. argument)`},
+	}
+
+	for i, tt := range tests {
+		err := tt.q.Check()
+		if tt.msg == "" {
+			if err != nil {
+				t.Errorf("tests[%d]: expecting no error, but got: %s", i, err)
+			}
+			continue
+		}
+
+		invErr, ok := err.(InvalidArgument)
+		if !ok {
+			t.Errorf("tests[%d]: error should be InvalidArgument, but is %T", i, err)
+			continue
+		}
+
+		if !strings.Contains(invErr.ErrorMessage, tt.msg) {
+			t.Errorf("tests[%d]: error message should contain %#v, but is %#v", i, tt.msg, invErr.ErrorMessage)
+		}
+	}
+}
+
+func TestCheckCycle(t *testing.T) {
+	q := Add(set, "a").SetName("outer")
+	inner := Add(appendString, PIPE).SetName("inner")
+	q.Add(read).Sub(inner)
+	inner.Add(appendString, Run(q))
+
+	err := q.Check()
+	cycErr, ok := err.(CycleError)
+	if !ok {
+		t.Fatalf("error should be CycleError, but is %T: %v", err, err)
+	}
+
+	if cycErr.Start != "" || cycErr.Path != "2/sub0/1/arg0/run0" || cycErr.Name != "outer" {
+		t.Errorf("wrong cycle error: %#v", cycErr)
+	}
+
+	expected := `queue "outer" is nested in itself at 2/sub0/1/arg0/run0`
+	if cycErr.Error() != expected {
+		t.Errorf("error message should be %#v, but is %#v", expected, cycErr.Error())
+	}
+
+	// queues that are nested more than once, but not in themselves are fine
+	sub := Add(appendString, PIPE)
+	if err := Add(read).Sub(sub, sub).Add(appendString, Fallback(sub, sub)).Check(); err != nil {
+		t.Errorf("expecting no error, but got: %s", err)
+	}
+
+	errs := Add(read).Sub(inner).TeeAndRun(q).CheckAll()
+	checkErrs, ok := errs.(CheckErrors)
+	if !ok || len(checkErrs) != 2 {
+		t.Fatalf("expecting 2 CheckErrors, but got: %v", errs)
+	}
+
+	if checkErrs[0].Path != "1/sub0/1/arg0/run0/2/sub0" || checkErrs[1].Path != "1/tee0/teeQueue0/2/sub0/1/arg0/run0" {
+		t.Errorf("wrong paths of CheckErrors: %s", checkErrs)
+	}
+
+	if e := checkErrs[0].Err.(CycleError); e.Start != "1/sub0" {
+		t.Errorf("cycle should start at 1/sub0, but starts at %#v", e.Start)
+	}
+}
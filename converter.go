@@ -0,0 +1,200 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"gopkg.in/go-on/queue.v2/internal/params"
+)
+
+// Converter converts arguments to the types of their parameters, if they are not
+// assignable, so that e.g. an int can be piped into an int64 parameter without a
+// wrapper function (see SetConverter()). It tries in this order
+//
+//   - the conversion functions registered via Register(), in the order of registration
+//   - the built-in conversions of Go (see reflect.Type.ConvertibleTo), apart from integers
+//     to strings (string(65) is "A") and slices to arrays (which panics for short slices)
+//   - unwrapping a value of an interface type, if the parameter is an interface or a type
+//     that implements it
+//
+// Check() only knows the static types of arguments, so it accepts interfaces that might be
+// unwrapped. In a run, the dynamic type of the value is converted instead and a value that
+// can't be converted is returned as InvalidArgument.
+type Converter struct {
+	mu    sync.RWMutex
+	funcs []reflect.Value
+}
+
+// NewConverter returns a Converter with the built-in conversions and no conversion functions
+func NewConverter() *Converter {
+	return &Converter{}
+}
+
+// Register registers a conversion function and may be chained. The function must be of the
+// form func(From) To or func(From) (To, error). It is used for arguments that are assignable
+// to From and parameters To is assignable to. A returned error is handled like an error
+// returned by the call that gets the argument.
+//
+// It panics if fn is not a conversion function.
+func (c *Converter) Register(fn interface{}) *Converter {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		panic(fmt.Sprintf("can't register converter: %T is no func", fn))
+	}
+	ftype := v.Type()
+	if ftype.NumIn() != 1 || ftype.IsVariadic() || ftype.NumOut() < 1 || ftype.NumOut() > 2 ||
+		(ftype.NumOut() == 2 && ftype.Out(1) != errorType) {
+		panic(fmt.Sprintf("can't register converter: %s is no func(From) To or func(From) (To, error)", ftype))
+	}
+	c.mu.Lock()
+	c.funcs = append(c.funcs, v)
+	c.mu.Unlock()
+	return c
+}
+
+// SetConverter sets the converter for the arguments of the calls of q. If c is nil, the
+// converter is removed.
+//
+// Nested queues without own converter use the converter of the queue they are nested in,
+// in a run as well as in Check().
+func (q *Queue) SetConverter(c *Converter) *Queue {
+	q.converter = c
+	return q
+}
+
+type converterKey struct{}
+
+// enterConverter returns the context for running the calls of q with the converter of q
+func (q *Queue) enterConverter(ctx context.Context) context.Context {
+	if q.converter == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, converterKey{}, q.converter)
+}
+
+// runConverter returns the converter of the run of the given context, nil if there is none
+func runConverter(ctx context.Context) *Converter {
+	c, _ := ctx.Value(converterKey{}).(*Converter)
+	return c
+}
+
+// conversion converts values of a type to the type of a parameter
+type conversion struct {
+	to reflect.Type
+
+	// registered conversion function, if any
+	fn reflect.Value
+
+	// the value is unwrapped from an interface
+	unwrap bool
+}
+
+func (cv conversion) String() string {
+	switch {
+	case cv.fn.IsValid():
+		return fmt.Sprintf("%s (%s)", cv.to, cv.fn.Type())
+	case cv.unwrap:
+		return fmt.Sprintf("%s (unwrapped)", cv.to)
+	default:
+		return fmt.Sprintf("%s (built-in)", cv.to)
+	}
+}
+
+// lookup returns the conversion from the type from to the type to. A nil converter has none.
+func (c *Converter) lookup(from, to reflect.Type) (conversion, bool) {
+	if c == nil {
+		return conversion{}, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, fn := range c.funcs {
+		if from.AssignableTo(fn.Type().In(0)) && fn.Type().Out(0).AssignableTo(to) {
+			return conversion{to: to, fn: fn}, true
+		}
+	}
+	if builtinConvertible(from, to) {
+		return conversion{to: to}, true
+	}
+	if from.Kind() == reflect.Interface && (to.Kind() == reflect.Interface || to.Implements(from)) {
+		return conversion{to: to, unwrap: true}, true
+	}
+	return conversion{}, false
+}
+
+func builtinConvertible(from, to reflect.Type) bool {
+	if !from.ConvertibleTo(to) {
+		return false
+	}
+	switch {
+	case to.Kind() == reflect.String && isInteger(from.Kind()):
+		return false
+	case from.Kind() == reflect.Slice && (to.Kind() == reflect.Array || to.Kind() == reflect.Ptr):
+		return false
+	}
+	return true
+}
+
+func isInteger(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+// assignable reports, if an argument of type is can be passed as should, maybe after a conversion
+func (c *Converter) assignable(is, should reflect.Type) bool {
+	if is.AssignableTo(should) {
+		return true
+	}
+	_, ok := c.lookup(is, should)
+	return ok
+}
+
+// conversions returns the conversions of the arguments of a function of type fn,
+// nil for arguments that are assignable. It returns nil, if there are none.
+func (c *Converter) conversions(fn reflect.Type, args []reflect.Type) (res []*conversion) {
+	for i, is := range args {
+		should := params.Type(fn, i)
+		if should == nil || is.AssignableTo(should) {
+			continue
+		}
+		if cv, ok := c.lookup(is, should); ok {
+			if res == nil {
+				res = make([]*conversion, len(args))
+			}
+			res[i] = &cv
+		}
+	}
+	return
+}
+
+// convertArgs converts the arguments of a function of type fn in place. It returns the
+// problem of the first argument that can't be converted as invalid and the error of a
+// conversion function as err.
+func (c *Converter) convertArgs(fn reflect.Type, vals []reflect.Value) (invalid, err error) {
+	for i, v := range vals {
+		should := params.Type(fn, i)
+		if should == nil || v.Type().AssignableTo(should) {
+			continue
+		}
+		// in a run, values have their dynamic type, so they are never unwrapped
+		cv, ok := c.lookup(v.Type(), should)
+		if !ok || cv.unwrap {
+			return fmt.Errorf("%d. argument is a %#v but should be a %#v", i+1, v.Type().String(), should.String()), nil
+		}
+		if !cv.fn.IsValid() {
+			vals[i] = v.Convert(should)
+			continue
+		}
+		returns := cv.fn.Call([]reflect.Value{v})
+		if len(returns) == 2 && !returns[1].IsNil() {
+			return nil, returns[1].Interface().(error)
+		}
+		vals[i] = returns[0]
+	}
+	return nil, nil
+}
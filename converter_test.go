@@ -0,0 +1,113 @@
+package queue
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type convName string
+
+func TestConverter(t *testing.T) {
+	var got []interface{}
+	i64 := func(i int64) { got = append(got, i) }
+	str := func(s string) { got = append(got, s) }
+	dur := func(d time.Duration) { got = append(got, d) }
+	ints := func(is ...int) { got = append(got, is) }
+	num := func() int { return 3 }
+	any := func(v interface{}) func() interface{} { return func() interface{} { return v } }
+
+	conv := NewConverter().Register(time.ParseDuration)
+
+	tests := []struct {
+		q        *Queue
+		expected interface{}
+	}{
+		{Add(num).Add(i64, PIPE), int64(3)},
+		{Add(i64, 4), int64(4)},
+		{Add(str, convName("a")), "a"},
+		{Add(str, []byte("b")), "b"},
+		{Add(dur, "2s"), 2 * time.Second},
+		{Add(dur, 5), time.Duration(5)},
+		{Add(any(6)).Add(i64, PIPE), int64(6)},
+		{Add(any(int64(7))).Add(ints, 1, PIPE), []int{1, 7}},
+		{New().Sub(Add(num).Add(i64, PIPE)), int64(3)},
+	}
+
+	for i, test := range tests {
+		if err := test.q.Check(); err == nil {
+			t.Errorf("tests[%d]: Check without converter should fail", i)
+		}
+
+		test.q.SetConverter(conv)
+		got = nil
+		if err := test.q.Check(); err != nil {
+			t.Errorf("tests[%d]: Check should accept, but got %s", i, err)
+		}
+		if err := test.q.Run(); err != nil {
+			t.Errorf("tests[%d]: Run should work, but got %s", i, err)
+		}
+		if len(got) != 1 || fmt.Sprintf("%#v", got[0]) != fmt.Sprintf("%#v", test.expected) {
+			t.Errorf("tests[%d]: expected %#v, but got %#v", i, test.expected, got)
+		}
+	}
+}
+
+func TestConverterErrors(t *testing.T) {
+	conv := NewConverter().Register(time.ParseDuration)
+	called := false
+	str := func(string) { called = true }
+	dur := func(time.Duration) { called = true }
+	num := func(int) { called = true }
+
+	// integers are not converted to strings
+	if err := Add(str, 65).SetConverter(conv).Check(); err == nil {
+		t.Errorf("Check should reject int for string")
+	}
+
+	// errors of conversion functions are handled like errors of the call
+	err := Add(dur, "x").SetConverter(conv).Run()
+	if err == nil || !strings.Contains(err.Error(), "time: invalid duration") {
+		t.Errorf("expecting error of time.ParseDuration, but got %v", err)
+	}
+
+	// unwrapped values that can't be converted
+	q := Add(func() interface{} { return "y" }).Add(num, PIPE).SetConverter(conv)
+	if err := q.Check(); err != nil {
+		t.Errorf("Check should accept interface{} for int, but got %s", err)
+	}
+	ia, ok := q.Run().(InvalidArgument)
+	if !ok || ia.ErrorMessage != `1. argument is a "string" but should be a "int"` {
+		t.Errorf("expecting InvalidArgument, but got %#v", ia)
+	}
+
+	if called {
+		t.Errorf("function should not be called")
+	}
+
+	for _, fn := range []interface{}{1, func() int { return 0 }, func(int) (int, int) { return 0, 0 }} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%T) should panic", fn)
+				}
+			}()
+			conv.Register(fn)
+		}()
+	}
+}
+
+func TestExplainConversions(t *testing.T) {
+	q := New().SetConverter(NewConverter().Register(time.ParseDuration)).
+		Add(func() (int, string) { return 0, "" }).
+		Add(func(int64, time.Duration) {}, PIPE)
+
+	var bf bytes.Buffer
+	q.Explain(&bf)
+	expected := `args:    int (PIPE) -> int64 (built-in), string (PIPE) -> time.Duration (func(string) (time.Duration, error))`
+	if !strings.Contains(bf.String(), expected) {
+		t.Errorf("expecting %q in\n%s", expected, bf.String())
+	}
+}
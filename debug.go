@@ -0,0 +1,202 @@
+package queue
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// Debugger pauses the runs of a queue at breakpoints (see SetDebugger()).
+//
+// A breakpoint matches a call or tee by its StepPath (see Check()) or by its name.
+// When a run reaches a breakpoint, the arguments of the call are resolved and the handler
+// of the breakpoint gets the paused DebugStep. The handler decides how the run continues
+// by calling one of the methods of the DebugStep. If it returns without a decision, the
+// run continues. If it panics, the panic is handled like a panic of the call (see CallPanic).
+//
+// Since queues passed via Race() or Hedge() run concurrently, handlers may be called concurrently.
+type Debugger struct {
+	mu          sync.RWMutex
+	breakpoints []breakpoint
+}
+
+type breakpoint struct {
+	path    StepPath
+	name    string
+	handler func(*DebugStep)
+}
+
+// NewDebugger returns a Debugger without breakpoints
+func NewDebugger() *Debugger {
+	return &Debugger{}
+}
+
+// BreakAt adds a breakpoint at the call or tee with the given path and may be chained
+func (d *Debugger) BreakAt(path StepPath, handler func(*DebugStep)) *Debugger {
+	return d.add(breakpoint{path: path, handler: handler})
+}
+
+// BreakNamed adds a breakpoint at the calls and tees with the given name and may be chained
+func (d *Debugger) BreakNamed(name string, handler func(*DebugStep)) *Debugger {
+	return d.add(breakpoint{name: name, handler: handler})
+}
+
+// Clear removes all breakpoints
+func (d *Debugger) Clear() {
+	d.mu.Lock()
+	d.breakpoints = nil
+	d.mu.Unlock()
+}
+
+func (d *Debugger) add(b breakpoint) *Debugger {
+	d.mu.Lock()
+	d.breakpoints = append(d.breakpoints, b)
+	d.mu.Unlock()
+	return d
+}
+
+// handlers returns the handlers of the breakpoints that match the call c at path
+func (d *Debugger) handlers(path StepPath, c *call) (handlers []func(*DebugStep)) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, b := range d.breakpoints {
+		if (b.name == "" && b.path == path) || (b.name != "" && b.name == c.name) {
+			handlers = append(handlers, b.handler)
+		}
+	}
+	return
+}
+
+// SendTo returns a breakpoint handler that sends the paused steps to ch and blocks the run
+// until the receiver decides how it continues, e.g. for an interactive stepper.
+func SendTo(ch chan<- *DebugStep) func(*DebugStep) {
+	return func(s *DebugStep) {
+		ch <- s
+		<-s.decided
+	}
+}
+
+// DebugAction is the decision how a run continues after a breakpoint
+type DebugAction int
+
+const (
+	// DebugContinue runs the call with its (maybe replaced) arguments
+	DebugContinue DebugAction = iota
+	// DebugSkip skips the call, the piped values are passed on
+	DebugSkip
+	// DebugFail skips the call, as if it returned an error
+	DebugFail
+	// DebugAbort stops the run, regardless of the error handlers
+	DebugAbort
+)
+
+// DebugStep is a call or tee that is paused at a breakpoint
+type DebugStep struct {
+	// path of the call or tee
+	Path StepPath
+
+	// name of the call or tee, if it is named
+	Name string
+
+	// type of the function
+	Type reflect.Type
+
+	// the resolved arguments of the call
+	Args []interface{}
+
+	// the current piped values
+	Piped []interface{}
+
+	action  DebugAction
+	err     error
+	once    sync.Once
+	decided chan struct{}
+}
+
+func (s *DebugStep) decide(action DebugAction, args []interface{}, err error) {
+	s.once.Do(func() {
+		s.action = action
+		if args != nil {
+			s.Args = args
+		}
+		s.err = err
+		close(s.decided)
+	})
+}
+
+// Continue runs the call with its arguments
+func (s *DebugStep) Continue() { s.decide(DebugContinue, nil, nil) }
+
+// Replace runs the call with the given arguments instead of the resolved ones
+func (s *DebugStep) Replace(args ...interface{}) {
+	if args == nil {
+		args = []interface{}{}
+	}
+	s.decide(DebugContinue, args, nil)
+}
+
+// Skip skips the call, so that the current piped values are passed on
+func (s *DebugStep) Skip() { s.decide(DebugSkip, nil, nil) }
+
+// Fail skips the call as if it returned err, which is handled by the error handlers
+func (s *DebugStep) Fail(err error) { s.decide(DebugFail, nil, err) }
+
+// Abort stops the run with Aborted, regardless of the error handlers
+func (s *DebugStep) Abort() { s.decide(DebugAbort, nil, nil) }
+
+// SetDebugger attaches the debugger d to q. If d is nil, the debugger is removed.
+//
+// Like the max depth, only the debugger of the queue that is run counts,
+// but its breakpoints apply to nested queues as well (see also SetRecorder()).
+func (q *Queue) SetDebugger(d *Debugger) *Queue {
+	q.debugger = d
+	return q
+}
+
+// debugAborted returns the Aborted error, if a step of the run of the given context was aborted
+func debugAborted(ctx context.Context) error {
+	if h, ok := ctx.Value(hooksKey{}).(runHooks); ok {
+		if err, ok := h.aborted.Load().(error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// debugStep pauses the run at the call c, if it has a breakpoint, and returns the decision
+// of the breakpoint handlers, the arguments to call c with and the error to return instead.
+func (q *Queue) debugStep(ctx context.Context, c *call, args []interface{}, piped []reflect.Value) (DebugAction, []interface{}, error) {
+	r, ok := ctx.Value(hooksKey{}).(runHooks)
+	if !ok || r.debugger == nil {
+		return DebugContinue, args, nil
+	}
+
+	for _, handler := range r.debugger.handlers(r.path, c) {
+		s := &DebugStep{
+			Path:    r.path,
+			Name:    c.name,
+			Type:    c.function.Type(),
+			Args:    args,
+			Piped:   toInterfaces(piped),
+			decided: make(chan struct{}),
+		}
+		handler(s)
+		s.Continue()
+		q.logDebug("[B] breakpoint at %s: %s", r.path, debugActionNames[s.action])
+
+		switch s.action {
+		case DebugFail:
+			return s.action, args, s.err
+		case DebugAbort:
+			err := Aborted{Path: r.path, Name: c.name}
+			r.aborted.Store(err)
+			return s.action, args, err
+		case DebugSkip:
+			return s.action, args, nil
+		}
+		args = s.Args
+	}
+	return DebugContinue, args, nil
+}
+
+var debugActionNames = [...]string{"continue", "skip", "fail", "abort"}
@@ -0,0 +1,167 @@
+package queue
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDebuggerPaths(t *testing.T) {
+	var paths []StepPath
+	record := func(s *DebugStep) { paths = append(paths, s.Path) }
+
+	d := NewDebugger()
+	for _, p := range []StepPath{"0", "0/tee0", "1/sub0/0", "2/arg0", "3/arg0/run0/0", "4/arg0/fallback1/0", "5/arg0/race0/0", "5/tee0/teeQueue0/0"} {
+		d.BreakAt(p, record)
+	}
+
+	result = ""
+	err := New().SetDebugger(d).
+		Add(set, "a").Tee(appendString, "t").
+		Sub(Add(appendString, "s")).
+		Add(appendString, Call(read)).
+		Add(appendString, Run(Add(read))).
+		Add(appendString, Fallback(Add(setErr, "e"), Add(read))).
+		Add(appendString, Race(Add(read))).TeeAndRun(Add(appendString, PIPE)).
+		Run()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []StepPath{"0", "0/tee0", "1/sub0/0", "2/arg0", "3/arg0/run0/0", "4/arg0/fallback1/0", "5/arg0/race0/0", "5/tee0/teeQueue0/0"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("breakpoints should be hit at %v, but are hit at %v", expected, paths)
+	}
+}
+
+func TestDebuggerStep(t *testing.T) {
+	var step *DebugStep
+	d := NewDebugger().BreakNamed("b", func(s *DebugStep) { step = s })
+
+	result = "x"
+	New().SetDebugger(d).Add(read).AddNamed("b", appendString, PIPE, "y").Run()
+
+	if step.Path != "1" || step.Name != "b" || step.Type != reflect.TypeOf(appendString) {
+		t.Errorf("wrong step: %#v", step)
+	}
+	if !reflect.DeepEqual(step.Args, []interface{}{"x", "y"}) || !reflect.DeepEqual(step.Piped, []interface{}{"x"}) {
+		t.Errorf("wrong args %#v or piped values %#v", step.Args, step.Piped)
+	}
+}
+
+func TestDebuggerDecisions(t *testing.T) {
+	errInjected := errors.New("injected")
+	tests := []struct {
+		decide func(*DebugStep)
+		result string
+		err    error
+	}{
+		{func(s *DebugStep) {}, "abc", nil},
+		{func(s *DebugStep) { s.Continue() }, "abc", nil},
+		{func(s *DebugStep) { s.Replace("B") }, "aBc", nil},
+		{func(s *DebugStep) { s.Skip() }, "ac", nil},
+		{func(s *DebugStep) { s.Fail(errInjected) }, "a", errInjected},
+		{func(s *DebugStep) { s.Abort() }, "a", Aborted{Path: "1", Name: "b"}},
+	}
+
+	for i, tt := range tests {
+		result = ""
+		err := New().SetDebugger(NewDebugger().BreakNamed("b", tt.decide)).
+			Add(set, "a").AddNamed("b", appendString, "b").Add(appendString, "c").Run()
+
+		if result != tt.result || err != tt.err {
+			t.Errorf("tests[%d]: expecting %#v and %v, but got %#v and %v", i, tt.result, tt.err, result, err)
+		}
+	}
+}
+
+func TestDebuggerFailHandled(t *testing.T) {
+	result = ""
+	d := NewDebugger().BreakAt("1", func(s *DebugStep) { s.Fail(errors.New("injected")) })
+	err := OnError(IGNORE).SetDebugger(d).Add(set, "a").Add(appendString, "b").Add(appendString, "c").Run()
+
+	if err != nil || result != "ac" {
+		t.Errorf("injected error should be ignored, but got %#v and %v", result, err)
+	}
+}
+
+func TestDebuggerAbortIgnoresErrHandlers(t *testing.T) {
+	result = ""
+	d := NewDebugger().BreakAt("0/arg0/run0/1", func(s *DebugStep) { s.Abort() })
+	err := OnError(IGNORE).SetDebugger(d).
+		Add(appendString, Run(Add(set, "a").Add(appendString, "b"))).Add(appendString, "c").
+		Run()
+
+	if _, ok := err.(Aborted); !ok || result != "a" {
+		t.Errorf("expecting Aborted and \"a\", but got %#v and %v", result, err)
+	}
+}
+
+func TestDebuggerAbortSkipsErrHandlersAndTees(t *testing.T) {
+	for name, h := range map[string]ErrHandler{"IGNORE": IGNORE, "PANIC": PANIC} {
+		result = ""
+		var teed bool
+		d := NewDebugger().BreakNamed("b", func(s *DebugStep) { s.Abort() })
+		var err error
+		func() {
+			defer func() {
+				if e := recover(); e != nil {
+					t.Errorf("%s: abort should not panic, but got %v", name, e)
+				}
+			}()
+			err = OnError(h).SetDebugger(d).
+				Add(set, "a").AddNamed("b", appendString, "b").
+				Tee(func() { teed = true }).
+				Add(appendString, "c").
+				Run()
+		}()
+		if _, ok := err.(Aborted); !ok || result != "a" || teed {
+			t.Errorf("%s: expecting Aborted, \"a\" and no tee, but got %v, %#v and %v", name, err, result, teed)
+		}
+	}
+
+	// aborted in a tee
+	result = ""
+	d := NewDebugger().BreakNamed("tee", func(s *DebugStep) { s.Abort() })
+	err := OnError(IGNORE).SetDebugger(d).
+		Add(set, "a").TeeNamed("tee", appendString, "t").TeeNamed("tee2", appendString, "u").
+		Add(appendString, "c").
+		Run()
+	if ab, ok := err.(Aborted); !ok || ab.Name != "tee" || result != "a" {
+		t.Errorf("expecting Aborted at the tee and \"a\", but got %v and %#v", err, result)
+	}
+}
+
+func TestDebuggerSendTo(t *testing.T) {
+	steps := make(chan *DebugStep)
+	d := NewDebugger().BreakAt("0", SendTo(steps)).BreakAt("1", SendTo(steps))
+
+	done := make(chan error)
+	result = ""
+	go func() {
+		done <- New().SetDebugger(d).Add(set, "a").Add(appendString, "b").Run()
+	}()
+
+	s := <-steps
+	s.Replace("x")
+	s = <-steps
+	if result != "x" {
+		t.Errorf("run should be paused after the first call, but result is %#v", result)
+	}
+	s.Skip()
+
+	if err := <-done; err != nil || result != "x" {
+		t.Errorf("expecting \"x\", but got %#v and %v", result, err)
+	}
+}
+
+func TestDebuggerHandlerPanic(t *testing.T) {
+	d := NewDebugger().BreakAt("1/sub0/0", func(s *DebugStep) { panic("boom") })
+	err := New().SetDebugger(d).Add(set, "a").Sub(Add(appendString, "b")).Run()
+
+	cp, ok := err.(CallPanic)
+	if !ok || cp.ErrorMessage != "boom" || cp.Path != "1/sub0/0" {
+		t.Errorf("expecting CallPanic at 1/sub0/0, but got %#v", err)
+	}
+}
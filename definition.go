@@ -0,0 +1,439 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"gopkg.in/go-on/queue.v2/internal/params"
+)
+
+// QueueDef is the declarative definition of a queue, whose functions and error handlers
+// are referenced by their names in a Registry.
+//
+// In JSON a queue that sets the age of a person and logs it, looks like
+//
+//	{
+//	  "onError": "IGNORE",
+//	  "steps": [
+//	    {"func": "get", "args": ["Age"]},
+//	    {"func": "atoi", "args": [{"pipe": true}], "tees": [{"func": "log", "args": [{"pipe": true}]}]},
+//	    {"func": "setAge", "args": [{"fallback": [{"steps": [...]}, {"steps": [...]}]}]},
+//	    {"sub": [{"steps": [...]}]}
+//	  ]
+//	}
+type QueueDef struct {
+	// name of the queue
+	Name string `json:"name,omitempty"`
+
+	// name of the error handler of the queue, STOP if empty
+	OnError string `json:"onError,omitempty"`
+
+	// failure convention of the calls of the queue (see FailOn()), "FailOnError" if empty
+	FailOn string `json:"failOn,omitempty"`
+
+	Steps []StepDef `json:"steps"`
+}
+
+// StepDef defines a call (Func and Args) or queues that are added via Sub()
+type StepDef struct {
+	// name of the call
+	Name string `json:"name,omitempty"`
+
+	// name of the function
+	Func string `json:"func,omitempty"`
+
+	Args []ArgDef `json:"args,omitempty"`
+
+	// failure convention of the call, the one of the queue if empty
+	FailOn string `json:"failOn,omitempty"`
+
+	// tees of the call
+	Tees []StepDef `json:"tees,omitempty"`
+
+	// queues added via Sub() instead of a call
+	Sub []QueueDef `json:"sub,omitempty"`
+}
+
+// ArgDef defines an argument of a call.
+//
+// In JSON, numbers, strings, booleans, null and arrays are literal arguments that are decoded
+// into the type of the parameter of the function. Objects define pseudo arguments:
+//
+//	{"pipe": true}               PIPE
+//	{"ctx": true}                CTX
+//	{"call": {"func": ...}}      Call()
+//	{"run": [{"steps": ...}]}    Run()
+//	{"fallback": [...]}          Fallback()
+//	{"value": {...}}             an object as literal argument
+type ArgDef struct {
+	Pipe     bool       `json:"pipe,omitempty"`
+	Ctx      bool       `json:"ctx,omitempty"`
+	Call     *StepDef   `json:"call,omitempty"`
+	Run      []QueueDef `json:"run,omitempty"`
+	Fallback []QueueDef `json:"fallback,omitempty"`
+
+	// JSON of a literal argument
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type argDefObject ArgDef
+
+func (a *ArgDef) UnmarshalJSON(data []byte) error {
+	if d := bytes.TrimSpace(data); len(d) == 0 || d[0] != '{' {
+		a.Value = append(json.RawMessage(nil), data...)
+		return nil
+	}
+	return json.Unmarshal(data, (*argDefObject)(a))
+}
+
+func (a ArgDef) MarshalJSON() ([]byte, error) {
+	if v := bytes.TrimSpace(a.Value); len(v) > 0 && v[0] != '{' && !a.Pipe && !a.Ctx &&
+		a.Call == nil && a.Run == nil && a.Fallback == nil {
+		return v, nil
+	}
+	return json.Marshal(argDefObject(a))
+}
+
+// failureByName returns the failure convention with the given name (see Failure)
+func failureByName(name string) (Failure, bool) {
+	for i, n := range failureNames {
+		if n == name && n != "" {
+			return Failure(i), true
+		}
+	}
+	return 0, false
+}
+
+func defErr(path StepPath, format string, a ...interface{}) error {
+	return DefinitionError{Path: path, ErrorMessage: fmt.Sprintf(format, a...)}
+}
+
+// Load builds the queue of the definition and checks it (see Check()).
+//
+// Literal arguments are decoded into the types of the parameters of the functions, therefore
+// the types of the piped values are inferred like in Check().
+func (r *Registry) Load(def *QueueDef) (*Queue, error) {
+	return r.LoadWithInput(def)
+}
+
+// LoadWithInput works like Load(), but the queue gets input values of the given types
+// (see RunWithInput()).
+func (r *Registry) LoadWithInput(def *QueueDef, inputTypes ...reflect.Type) (*Queue, error) {
+	q, err := r.Build(def, inputTypes...)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.check(inputTypes); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Build builds the queue of the definition like LoadWithInput(), but does not check it,
+// so that an invalid queue may be inspected (e.g. with Explain()).
+func (r *Registry) Build(def *QueueDef, inputTypes ...reflect.Type) (*Queue, error) {
+	q, _, err := r.build(def, "", inputTypes)
+	return q, err
+}
+
+// LoadJSON loads the queue of the QueueDef in JSON (see Load())
+func (r *Registry) LoadJSON(data []byte) (*Queue, error) {
+	var def QueueDef
+	if err := json.Unmarshal(data, &def); err != nil {
+		return nil, err
+	}
+	return r.Load(&def)
+}
+
+// build builds the queue of the definition that gets the piped types and returns the types returned by the queue
+func (r *Registry) build(def *QueueDef, path StepPath, piped []reflect.Type) (q *Queue, returns []reflect.Type, err error) {
+	q = New().SetName(def.Name)
+	if def.OnError != "" {
+		h, ok := r.handlers[def.OnError]
+		if !ok {
+			return nil, nil, defErr(path, "unknown error handler %#v", def.OnError)
+		}
+		q.OnError(h)
+	}
+	if def.FailOn != "" {
+		f, ok := failureByName(def.FailOn)
+		if !ok {
+			return nil, nil, defErr(path, "unknown failure convention %#v", def.FailOn)
+		}
+		q.FailOn(f)
+	}
+
+	for i, st := range def.Steps {
+		p := path.childN("", i)
+		if len(st.Sub) > 0 {
+			subs := make([]Queuer, len(st.Sub))
+			returns = piped
+			for k := range st.Sub {
+				subs[k], returns, err = r.build(&st.Sub[k], p.childN("sub", k), returns)
+				if err != nil {
+					return
+				}
+			}
+			q.Sub(subs...)
+		} else {
+			var c *call
+			c, returns, err = r.call(q, &st, p, piped)
+			if err != nil {
+				return
+			}
+			q.calls = append(q.calls, c)
+		}
+
+		for j := range st.Tees {
+			var tee *call
+			tee, _, err = r.call(q, &st.Tees[j], p.childN("tee", j), returns)
+			if err != nil {
+				return
+			}
+			q.tees[i] = append(q.tees[i], tee)
+		}
+		piped = returns
+	}
+	return q, piped, nil
+}
+
+// call builds the call of st for the queue q that gets the piped types and returns the types
+// returned by the call
+func (r *Registry) call(q *Queue, st *StepDef, path StepPath, piped []reflect.Type) (c *call, returns []reflect.Type, err error) {
+	if st.Func == "" {
+		return nil, nil, defErr(path, "missing func")
+	}
+	fn, ok := r.funcs[st.Func]
+	if !ok {
+		return nil, nil, defErr(path, "unknown func %#v", st.Func)
+	}
+	ftype := fn.Type()
+	c = &call{function: fn, name: st.Name}
+	if st.FailOn != "" {
+		f, ok := failureByName(st.FailOn)
+		if !ok {
+			return nil, nil, defErr(path, "unknown failure convention %#v", st.FailOn)
+		}
+		c.FailOn(f)
+	}
+
+	// number of values passed before the current argument
+	pos := 0
+	for j, a := range st.Args {
+		p := path.childN("arg", j)
+		var arg interface{}
+		var types []reflect.Type
+
+		switch {
+		case a.Pipe:
+			arg, types = PIPE, piped
+		case a.Ctx:
+			arg, types = CTX, []reflect.Type{contextType}
+		case a.Call != nil:
+			arg, types, err = r.call(q, a.Call, p, piped)
+		case a.Run != nil:
+			qs := make(callrun, len(a.Run))
+			types = piped
+			for k := range a.Run {
+				qs[k], types, err = r.build(&a.Run[k], p.childN("run", k), types)
+				if err != nil {
+					return
+				}
+			}
+			arg = qs
+		case a.Fallback != nil:
+			qs := make(callfallback, len(a.Fallback))
+			for k := range a.Fallback {
+				var alt []reflect.Type
+				qs[k], alt, err = r.build(&a.Fallback[k], p.childN("fallback", k), piped)
+				if err != nil {
+					return
+				}
+				if k == 0 {
+					types = alt
+				}
+			}
+			arg = qs
+		default:
+			arg, err = decodeArg(a.Value, params.Type(ftype, pos))
+			if err != nil {
+				return nil, nil, defErr(p, "can't decode argument %s: %s", a.Value, err)
+			}
+			types = []reflect.Type{nil}
+		}
+		if err != nil {
+			return
+		}
+		c.arguments = append(c.arguments, arg)
+		pos += len(types)
+	}
+	return c, returnTypes(ftype, q.failureOf(c)), nil
+}
+
+// decodeArg decodes the JSON of a literal argument into a value of type t
+func decodeArg(data json.RawMessage, t reflect.Type) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("missing value")
+	}
+	if t == nil {
+		var v interface{}
+		err := json.Unmarshal(data, &v)
+		return v, err
+	}
+	v := reflect.New(t)
+	if err := json.Unmarshal(data, v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface(), nil
+}
+
+// Marshal returns the QueueDef of the queue q. Every function and error handler of the queue
+// has to be registered, literal arguments have to be marshallable to JSON.
+//
+// Race(), Hedge(), TeeAndRun(), TeeAndFallback(), error handlers of calls, SetConverter(),
+// SetMaxDepth(), AutoClose() and Around() are not supported. The failure conventions of
+// FailOnAt() become the ones of the calls.
+func (r *Registry) Marshal(q *Queue) (*QueueDef, error) {
+	return r.marshal(q, "")
+}
+
+// MarshalIndent returns the QueueDef of the queue q in indented JSON (see Marshal())
+func (r *Registry) MarshalIndent(q *Queue) ([]byte, error) {
+	def, err := r.Marshal(q)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(def, "", "  ")
+}
+
+func (r *Registry) marshal(q *Queue, path StepPath) (*QueueDef, error) {
+	def := &QueueDef{Name: q.name, Steps: []StepDef{}}
+	if q.errHandler != nil {
+		name, ok := r.errHandlerName(q.errHandler)
+		if !ok {
+			return nil, defErr(path, "error handler %T is not registered", q.errHandler)
+		}
+		def.OnError = name
+	}
+	if q.inheritance != Isolate {
+		return nil, defErr(path, "Inheritance %s is not supported", q.inheritance)
+	}
+	if len(q.tees[-1]) > 0 {
+		return nil, defErr(path, "tees before the first call are not supported")
+	}
+	switch {
+	case q.converter != nil:
+		return nil, defErr(path, "SetConverter() is not supported")
+	case q.maxDepth != 0:
+		return nil, defErr(path, "SetMaxDepth() is not supported")
+	case q.autoClose:
+		return nil, defErr(path, "AutoClose() is not supported")
+	case q.around != nil:
+		return nil, defErr(path, "Around() is not supported")
+	}
+	if q.failure != 0 {
+		def.FailOn = q.failure.String()
+	}
+
+	for i, c := range q.calls {
+		p := path.childN("", i)
+		st := &StepDef{}
+		if c.function.Type() == queuersType {
+			for k, qe := range c.function.Interface().([]Queuer) {
+				sub, err := r.marshal(qe.Queue(), p.childN("sub", k))
+				if err != nil {
+					return nil, err
+				}
+				st.Sub = append(st.Sub, *sub)
+			}
+		} else {
+			var err error
+			st, err = r.marshalCall(q, c, p)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for j, tee := range q.tees[i] {
+			t, err := r.marshalCall(q, tee, p.childN("tee", j))
+			if err != nil {
+				return nil, err
+			}
+			st.Tees = append(st.Tees, *t)
+		}
+		def.Steps = append(def.Steps, *st)
+	}
+	return def, nil
+}
+
+func (r *Registry) marshalCall(q *Queue, c *call, path StepPath) (*StepDef, error) {
+	if c.feed != 0 {
+		return nil, defErr(path, "TeeAndRun() and TeeAndFallback() are not supported")
+	}
+	if q.stepErrHandler(c) != nil {
+		return nil, defErr(path, "error handlers of calls are not supported")
+	}
+	name, ok := r.funcName(c.function)
+	if !ok {
+		return nil, defErr(path, "function %s is not registered", c.function.Type())
+	}
+	st := &StepDef{Name: c.name, Func: name}
+	if f := q.failuresAt[c.name]; f != 0 {
+		st.FailOn = f.String()
+	} else if c.failure != 0 {
+		st.FailOn = c.failure.String()
+	}
+
+	for j, arg := range c.arguments {
+		p := path.childN("arg", j)
+		var a ArgDef
+		switch v := arg.(type) {
+		case pipe:
+			a.Pipe = true
+		case ctxArg:
+			a.Ctx = true
+		case *call:
+			nested, err := r.marshalCall(q, v, p)
+			if err != nil {
+				return nil, err
+			}
+			a.Call = nested
+		case callrun:
+			qs, err := r.marshalQueues(v, p, "run")
+			if err != nil {
+				return nil, err
+			}
+			a.Run = qs
+		case callfallback:
+			qs, err := r.marshalQueues(v, p, "fallback")
+			if err != nil {
+				return nil, err
+			}
+			a.Fallback = qs
+		case callrace:
+			return nil, defErr(p, "Race() and Hedge() are not supported")
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, defErr(p, "can't marshal argument: %s", err)
+			}
+			a.Value = data
+		}
+		st.Args = append(st.Args, a)
+	}
+	return st, nil
+}
+
+func (r *Registry) marshalQueues(qs []Queuer, path StepPath, segment string) ([]QueueDef, error) {
+	defs := []QueueDef{}
+	for k, qe := range qs {
+		def, err := r.marshal(qe.Queue(), path.childN(segment, k))
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, *def)
+	}
+	return defs, nil
+}
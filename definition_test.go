@@ -0,0 +1,179 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func testRegistry() *Registry {
+	return NewRegistry().
+		Register("set", set).
+		Register("read", read).
+		Register("appendString", appendString).
+		Register("appendStringErr", appendStringErr).
+		Register("appendInts", appendInts).
+		Register("appendIntAndString", appendIntAndString).
+		Register("atoi", strconv.Atoi).
+		Register("itoa", strconv.Itoa)
+}
+
+const testDefinition = `{
+  "name": "def",
+  "onError": "IGNORE",
+  "steps": [
+    {"func": "set", "args": ["4"], "tees": [{"func": "appendString", "args": ["-"]}]},
+    {"name": "read", "func": "read"},
+    {"func": "atoi", "args": [{"pipe": true}]},
+    {"func": "appendIntAndString", "args": [{"pipe": true}, "a"]},
+    {"func": "appendInts", "args": [1, 2, {"call": {"func": "atoi", "args": ["3"]}}]},
+    {"func": "appendStringErr", "args": ["e"]},
+    {"sub": [{"steps": [{"func": "appendString", "args": ["s"]}]}]},
+    {"func": "appendString", "args": [{"fallback": [
+      {"steps": [{"func": "atoi", "args": ["x"]}, {"func": "itoa", "args": [{"pipe": true}]}]},
+      {"steps": [{"func": "read"}]}
+    ]}]}
+  ]
+}`
+
+func TestLoadJSON(t *testing.T) {
+	q, err := testRegistry().LoadJSON([]byte(testDefinition))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result = ""
+	if err := q.Run(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := "4-0a123es4-0a123es"
+	if result != expected {
+		t.Errorf("result should be %#v, but is %#v", expected, result)
+	}
+
+	if q.Name() != "def" || q.Steps()[1].Name != "read" {
+		t.Errorf("names are not set")
+	}
+}
+
+func TestMarshalIndent(t *testing.T) {
+	r := testRegistry()
+	q, err := r.LoadJSON([]byte(testDefinition))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := r.MarshalIndent(q)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var got, expected interface{}
+	json.Unmarshal(data, &got)
+	json.Unmarshal([]byte(testDefinition), &expected)
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("marshalled definition differs, got:\n%s", data)
+	}
+
+	q2, err := r.LoadJSON(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result = ""
+	q2.Run()
+	if result != "4-0a123es4-0a123es" {
+		t.Errorf("loaded queue behaves differently: %#v", result)
+	}
+}
+
+func TestDefinitionErrors(t *testing.T) {
+	r := testRegistry()
+	tests := []struct {
+		json string
+		msg  string
+	}{
+		{`{"steps": [{"func": "unknown"}]}`, `invalid queue definition at 0: unknown func "unknown"`},
+		{`{"onError": "unknown", "steps": []}`, `unknown error handler "unknown"`},
+		{`{"steps": [{"func": "read"}, {"func": "appendInts", "args": [1, "x"]}]}`, `invalid queue definition at 1/arg1: can't decode argument "x"`},
+		{`{"steps": [{"sub": [{"steps": [{}]}]}]}`, `invalid queue definition at 0/sub0/0: missing func`},
+		{`{"steps": [{"func": "read"}, {"func": "atoi", "args": [{"pipe": true}, {"pipe": true}]}]}`, `func wants 1 arguments, but gets 2`},
+		{`{"failOn": "unknown", "steps": []}`, `unknown failure convention "unknown"`},
+		{`{"steps": [{"func": "read", "failOn": "unknown"}]}`, `invalid queue definition at 0: unknown failure convention "unknown"`},
+	}
+
+	for i, tt := range tests {
+		_, err := r.LoadJSON([]byte(tt.json))
+		if err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("tests[%d]: error should contain %#v, but is %v", i, tt.msg, err)
+		}
+	}
+
+	unregistered := []*Queue{
+		Add(setToX),
+		Add(set, "x").OnError(ErrHandlerFunc(func(err error) error { return nil })),
+		Add(read).TeeAndRun(Add(appendString, PIPE)),
+		Add(appendString, Race(Add(read))),
+		Add(read).SetConverter(NewConverter()),
+		Add(read).SetMaxDepth(3),
+		Add(read).AutoClose(),
+		Add(read).Around(func(ctx context.Context, run func(context.Context) (error, error)) error {
+			_, err := run(ctx)
+			return err
+		}),
+	}
+
+	for i, q := range unregistered {
+		_, err := r.Marshal(q)
+		if _, ok := err.(DefinitionError); !ok {
+			t.Errorf("unregistered[%d]: expecting DefinitionError, but got %v", i, err)
+		}
+	}
+}
+
+func TestMarshalFailOn(t *testing.T) {
+	lookup := func(key string) (string, bool) { return key, key != "" }
+	appendOK := func(s string, ok bool) error { return appendString(s, strconv.FormatBool(ok)) }
+	r := testRegistry().Register("lookup", lookup).Register("appendOK", appendOK)
+
+	q := New().FailOn(FailOnFalse).
+		Add(lookup, "a").
+		Add(appendString, PIPE).
+		Add(appendOK, Call(lookup, "b").FailOn(FailOnError)).
+		Add(appendOK, CallNamed("strict", lookup, "c")).
+		FailOnAt("strict", FailOnError)
+
+	data, err := r.MarshalIndent(q)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var got, expected interface{}
+	json.Unmarshal(data, &got)
+	json.Unmarshal([]byte(`{
+  "failOn": "FailOnFalse",
+  "steps": [
+    {"func": "lookup", "args": ["a"]},
+    {"func": "appendString", "args": [{"pipe": true}]},
+    {"func": "appendOK", "args": [{"call": {"func": "lookup", "args": ["b"], "failOn": "FailOnError"}}]},
+    {"func": "appendOK", "args": [{"call": {"name": "strict", "func": "lookup", "args": ["c"], "failOn": "FailOnError"}}]}
+  ]
+}`), &expected)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("marshalled definition differs, got:\n%s", data)
+	}
+
+	q2, err := r.LoadJSON(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	result = ""
+	if err := q2.Run(); err != nil || result != "abtruectrue" {
+		t.Errorf("loaded queue behaves differently: %#v, %v", result, err)
+	}
+}
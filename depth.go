@@ -0,0 +1,81 @@
+package queue
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// DefaultMaxDepth is the max nesting depth of queues in a run, if the run queue
+// has no own max depth (see SetMaxDepth())
+var DefaultMaxDepth = 1000
+
+// SetMaxDepth sets the max nesting depth of queues (via Sub(), Run(), Fallback(), Race(),
+// TeeAndRun() etc.), when q is run. A queue that would be nested deeper, stops the run with
+// MaxDepthExceeded instead of overflowing the stack, e.g. if a queue is nested in itself.
+//
+// The max depth of nested queues is ignored, only the max depth of the queue that is run counts.
+// If depth is 0, DefaultMaxDepth is used.
+//
+// Once the max depth is exceeded, the whole run is stopped, regardless of the error handlers.
+func (q *Queue) SetMaxDepth(depth int) *Queue {
+	q.maxDepth = depth
+	return q
+}
+
+type depthKey struct{}
+
+// runDepth is the nesting depth of a queue in a run
+type runDepth struct {
+	depth int
+	limit *depthLimit
+}
+
+// depthLimit is shared by all queues of a run
+type depthLimit struct {
+	max int
+
+	// the MaxDepthExceeded error, once the max depth is exceeded
+	exceeded atomic.Value
+}
+
+// enterDepth returns the context for running the calls of q, nested inside the queue
+// of the given context. If q would exceed the max depth, MaxDepthExceeded is returned.
+func (q *Queue) enterDepth(ctx context.Context) (context.Context, error) {
+	d, ok := ctx.Value(depthKey{}).(runDepth)
+	if !ok {
+		max := q.maxDepth
+		if max == 0 {
+			max = DefaultMaxDepth
+		}
+		d.limit = &depthLimit{max: max}
+	}
+	if err := d.limit.err(); err != nil {
+		return ctx, err
+	}
+
+	d.depth++
+	if d.depth > d.limit.max {
+		err := MaxDepthExceeded{MaxDepth: d.limit.max, Name: q.name}
+		d.limit.exceeded.Store(err)
+		q.logDebug("[D] %s", err)
+		return ctx, err
+	}
+	return context.WithValue(ctx, depthKey{}, d), nil
+}
+
+// err returns the MaxDepthExceeded error, if the max depth was exceeded in the run
+func (l *depthLimit) err() error {
+	if err, ok := l.exceeded.Load().(error); ok {
+		return err
+	}
+	return nil
+}
+
+// depthExceeded returns the MaxDepthExceeded error, if the max depth was exceeded in the
+// run of the given context
+func depthExceeded(ctx context.Context) error {
+	if d, ok := ctx.Value(depthKey{}).(runDepth); ok {
+		return d.limit.err()
+	}
+	return nil
+}
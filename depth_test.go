@@ -0,0 +1,73 @@
+package queue
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaxDepth(t *testing.T) {
+	result = ""
+	q := Add(appendString, "x").SetName("self")
+	q.Sub(q)
+
+	err := q.Run()
+	depthErr, ok := err.(MaxDepthExceeded)
+	if !ok {
+		t.Fatalf("error should be MaxDepthExceeded, but is %T: %v", err, err)
+	}
+
+	if depthErr.MaxDepth != DefaultMaxDepth || depthErr.Name != "self" {
+		t.Errorf("wrong error: %#v", depthErr)
+	}
+
+	if len(result) != DefaultMaxDepth {
+		t.Errorf("queue should be run %d times, but was run %d times", DefaultMaxDepth, len(result))
+	}
+
+	result = ""
+	q.SetMaxDepth(3)
+	err = q.Run()
+
+	if _, ok := err.(MaxDepthExceeded); !ok || result != "xxx" {
+		t.Errorf("expecting MaxDepthExceeded after 3 runs, but got %v and %#v", err, result)
+	}
+}
+
+func TestMaxDepthIgnoresErrHandlers(t *testing.T) {
+	result = ""
+	q := Add(appendString, "x").SetName("self").SetMaxDepth(5).OnError(IGNORE)
+	q.Add(appendString, Fallback(q, q)).Add(appendString, "y")
+
+	err := q.Run()
+	if _, ok := err.(MaxDepthExceeded); !ok {
+		t.Fatalf("error should be MaxDepthExceeded, but is %T: %v", err, err)
+	}
+
+	if result != strings.Repeat("x", 5) {
+		t.Errorf("result should be %#v, but is %#v", strings.Repeat("x", 5), result)
+	}
+}
+
+func TestMaxDepthNotExceeded(t *testing.T) {
+	result = ""
+	inner := Add(appendString, "i")
+	q := Add(appendString, "a").Sub(Add(appendString, "b").Sub(inner)).SetMaxDepth(3)
+
+	if err := q.Run(); err != nil {
+		t.Fatalf("expecting no error, but got: %s", err)
+	}
+
+	if result != "abi" {
+		t.Errorf("result should be %#v, but is %#v", "abi", result)
+	}
+}
+
+func TestCloneCycle(t *testing.T) {
+	q := Add(appendString, "x")
+	q.Sub(q)
+
+	cl := q.Clone()
+	if cl.Steps()[1].Queues[0] != cl {
+		t.Errorf("clone should be nested in itself")
+	}
+}
@@ -1,5 +1,11 @@
 package queue
 
+import (
+	"context"
+	"reflect"
+	"strconv"
+)
+
 type (
 	// Each Queue has an error handler that is called if
 	// a function returns an error.
@@ -56,3 +62,176 @@ func (q *Queue) OnError(handler ErrHandler) *Queue {
 	q.errHandler = handler
 	return q
 }
+
+// OnErrorAt sets the given handler as error handler of every call and tee of q with the given name
+// (including calls passed as arguments via CallNamed()) and may be chained. It applies to calls
+// that are added later as well. Calls of nested queues have their own OnErrorAt().
+//
+// Errors of these calls are passed to the given handler instead of the error handler of
+// the queue. To pass unhandled errors on to the error handler of the queue, wrap the
+// handler with FallThrough(). The handler replaces an error handler set with OnError() of the call.
+func (q *Queue) OnErrorAt(name string, handler ErrHandler) *Queue {
+	if q.errHandlersAt == nil {
+		q.errHandlersAt = map[string]ErrHandler{}
+	}
+	q.errHandlersAt[name] = handler
+	return q
+}
+
+// stepErrHandler returns the error handler of the call c of q, nil if it has none
+func (q *Queue) stepErrHandler(c *call) ErrHandler {
+	if h, ok := q.errHandlersAt[c.name]; ok {
+		return h
+	}
+	return c.errHandler
+}
+
+type fallThrough struct{ ErrHandler }
+
+// FallThrough wraps an error handler of a call, so that errors that are not handled by it
+// (the given error is returned unchanged) are passed to the error handler of the queue.
+func FallThrough(handler ErrHandler) ErrHandler { return fallThrough{handler} }
+
+// Inheritance defines which error handler a queue uses when it is nested in another queue,
+// i.e. passed to Sub(), Run(), Fallback(), Race(), Hedge(), TeeAndRun() or TeeAndFallback().
+//
+// The queue it is nested in is called the parent. The error handler of the parent is the
+// one that the parent uses itself (which might be inherited as well).
+//
+// Errors returned by a nested queue are passed to the error handler of the parent
+// (for Fallback(), Race(), Hedge() and TeeAndFallback() only if all alternatives fail).
+// Errors of calls with their own error handler (see OnErrorAt()) are handled by them,
+// regardless of the Inheritance.
+type Inheritance int
+
+const (
+	// Isolate lets the queue use its own error handler, STOP if none is set (default).
+	Isolate Inheritance = iota
+
+	// Inherit lets a queue without an error handler use the error handler of the parent.
+	Inherit
+
+	// Override lets the queue use the error handler of the parent, even if it has its own.
+	// The own error handler is only used, if the queue is not nested.
+	Override
+)
+
+var inheritanceNames = [...]string{"Isolate", "Inherit", "Override"}
+
+func (i Inheritance) String() string {
+	if i < 0 || int(i) >= len(inheritanceNames) {
+		return "Inheritance(" + strconv.Itoa(int(i)) + ")"
+	}
+	return inheritanceNames[i]
+}
+
+// SetInheritance sets which error handler is used, if the queue is nested in another queue.
+// See Inheritance for the details.
+func (q *Queue) SetInheritance(mode Inheritance) *Queue {
+	q.inheritance = mode
+	return q
+}
+
+// used to pass the error handler of a queue to its nested queues
+type errHandlerKey struct{}
+
+// errHandlerFor returns the error handler of the queue for a run with the given context
+func (q *Queue) errHandlerFor(ctx context.Context) ErrHandler {
+	parent, _ := ctx.Value(errHandlerKey{}).(ErrHandler)
+	return q.resolveErrHandler(parent)
+}
+
+// resolveErrHandler returns the error handler of the queue, if the parent has the given
+// error handler (nil, if the queue is not nested)
+func (q *Queue) resolveErrHandler(parent ErrHandler) ErrHandler {
+	switch {
+	case parent != nil && q.inheritance == Override:
+		return parent
+	case q.errHandler != nil:
+		return q.errHandler
+	case parent != nil && q.inheritance == Inherit:
+		return parent
+	}
+	// default error handler is STOP
+	return STOP
+}
+
+// runErrHandler returns the error handler that the queue uses in the run with the given context
+func (q *Queue) runErrHandler(ctx context.Context) ErrHandler {
+	if h, ok := ctx.Value(errHandlerKey{}).(ErrHandler); ok {
+		return h
+	}
+	return q.resolveErrHandler(nil)
+}
+
+// ErrHandlerAt returns the error handler that handles the errors of the call or tee with the given name,
+// when the queue is run. Nested queues are searched as well, respecting their Inheritance.
+// If there is no such call, nil is returned.
+func (q *Queue) ErrHandlerAt(name string) ErrHandler {
+	return q.errHandlerAt(name, nil, map[*Queue]bool{})
+}
+
+// errHandlerAt searches the call with the given name in q, if q is nested in a queue with the
+// parent error handler. Queues that are nested in themselves are searched once, since walking
+// holds the queues that are currently searched.
+func (q *Queue) errHandlerAt(name string, parent ErrHandler, walking map[*Queue]bool) ErrHandler {
+	if walking[q] {
+		return nil
+	}
+	walking[q] = true
+	defer delete(walking, q)
+
+	errHandler := q.resolveErrHandler(parent)
+	for i, c := range q.calls {
+		if h := c.errHandlerAt(q, name, errHandler, walking); h != nil {
+			return h
+		}
+		for _, tee := range q.tees[i] {
+			if h := tee.errHandlerAt(q, name, errHandler, walking); h != nil {
+				return h
+			}
+		}
+	}
+	return nil
+}
+
+// handleStepError passes the non nil err returned by c to the error handler of c, if there is one.
+// It reports, if the resulting error should be passed to the error handler of the queue.
+func (q *Queue) handleStepError(c *call, err error, tag string) (err2 error, unhandled bool) {
+	h := q.stepErrHandler(c)
+	if h == nil {
+		return err, true
+	}
+	err2 = h.HandleError(err)
+	q.logDebug("[%s] %T(%#v) => %#v", tag, h, err, err2)
+	_, isFallThrough := h.(fallThrough)
+	return err2, isFallThrough && sameError(err, err2)
+}
+
+// handleError passes the non nil err returned by c to the error handler of c and/or
+// the given error handler of the queue
+func (q *Queue) handleError(c *call, errHandler ErrHandler, err error, tag string) error {
+	err, unhandled := q.handleStepError(c, err, tag)
+	if !unhandled {
+		return err
+	}
+	err2 := errHandler.HandleError(err)
+	q.logDebug("[%s] %T(%#v) => %#v", tag, errHandler, err, err2)
+	return err2
+}
+
+// sameError checks, if a and b are the same error, even if the type of the errors
+// is not comparable
+func sameError(a, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ta := reflect.TypeOf(a)
+	if ta != reflect.TypeOf(b) {
+		return false
+	}
+	if ta.Comparable() {
+		return a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
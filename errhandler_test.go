@@ -1,6 +1,7 @@
 package queue
 
 import (
+	"fmt"
 	"strconv"
 	"testing"
 )
@@ -68,3 +69,244 @@ func TestCatchHandleNot(t *testing.T) {
 		t.Errorf("wrong value, expecting 30, but got %d", s.Get())
 	}
 }
+
+func TestAddWithHandler(t *testing.T) {
+	s := &S{4}
+	err := New().
+		Add(s.Set, 30).
+		AddWithHandler(IGNORE, s.Add, 6).
+		Add(s.Add, 10).
+		Run()
+
+	if err != nil {
+		t.Errorf("expecting no returned error, but got %s", err.Error())
+	}
+
+	if s.Get() != 40 {
+		t.Errorf("wrong value, expecting 40, but got %d", s.Get())
+	}
+}
+
+func TestOnErrorAt(t *testing.T) {
+	s := &S{4}
+	err := New().
+		Add(s.Set, 30).
+		AddNamed("optional", s.Add, 6).
+		Add(s.Add, 10).
+		Add(s.Set, Call(strconv.Atoi, "x")).
+		OnErrorAt("optional", IGNORE).
+		Run()
+
+	if err == nil {
+		t.Errorf("expecting returned error, but got none")
+	}
+
+	if _, ok := err.(*strconv.NumError); !ok {
+		t.Errorf("error should be *strconv.NumError, but is: %T", err)
+	}
+
+	if s.Get() != 40 {
+		t.Errorf("wrong value, expecting 40, but got %d", s.Get())
+	}
+}
+
+func TestOnErrorAtOrder(t *testing.T) {
+	fail := func() error { return fmt.Errorf("fail") }
+
+	// the handler applies to calls that are added later
+	q := New().OnErrorAt("optional", IGNORE).AddNamed("optional", fail)
+	if err := q.Run(); err != nil {
+		t.Errorf("expecting error of later added call to be ignored, but got %v", err)
+	}
+	if h := q.ErrHandlerAt("optional"); fmt.Sprintf("%p", h) != fmt.Sprintf("%p", IGNORE) {
+		t.Errorf("ErrHandlerAt should return IGNORE, but got %p", h)
+	}
+
+	// and to replaced and cloned calls
+	q = New().AddNamed("optional", Ok).OnErrorAt("optional", IGNORE)
+	if err := q.Replace("optional", fail); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Clone().Run(); err != nil {
+		t.Errorf("expecting error of replaced call to be ignored, but got %v", err)
+	}
+
+	// FailOnAt as well
+	lookup := func() (int, bool) { return 0, false }
+	err := New().FailOnAt("lookup", FailOnFalse).AddNamed("lookup", lookup).Add(func(int) {}, PIPE).Run()
+	if _, ok := err.(NotOK); !ok {
+		t.Errorf("expecting NotOK of later added call, but got %v", err)
+	}
+}
+
+func TestCallOnError(t *testing.T) {
+	s := &S{4}
+	err := New().
+		Add(s.Set, Call(strconv.Atoi, "x").OnError(IGNORE)).
+		Run()
+
+	if err != nil {
+		t.Errorf("expecting no returned error, but got %s", err.Error())
+	}
+
+	if s.Get() != 0 {
+		t.Errorf("wrong value, expecting 0, but got %d", s.Get())
+	}
+}
+
+func TestTeeWithHandler(t *testing.T) {
+	s := &S{4}
+	var teed []int
+	err := New().
+		Add(Value, 6).
+		TeeWithHandler(IGNORE, s.Add, PIPE).
+		Tee(func(i int) { teed = append(teed, i) }, PIPE).
+		Add(s.Add, 1).
+		Run()
+
+	if err != nil {
+		t.Errorf("expecting no returned error, but got %s", err.Error())
+	}
+
+	if s.Get() != 5 || len(teed) != 1 {
+		t.Errorf("wrong value, expecting 5 and 1 tee call, but got %d and %d", s.Get(), len(teed))
+	}
+}
+
+func TestFallThrough(t *testing.T) {
+	s := &S{4}
+	var catched []error
+	queueHandler := ErrHandlerFunc(func(err error) error {
+		catched = append(catched, err)
+		return nil
+	})
+	onlyNumErrors := FallThrough(ErrHandlerFunc(func(err error) error {
+		if _, ok := err.(numError); ok {
+			return nil
+		}
+		return err
+	}))
+
+	err := OnError(queueHandler).
+		AddWithHandler(onlyNumErrors, s.Set, 5).
+		AddWithHandler(onlyNumErrors, s.Add, 6).
+		Run()
+
+	if err != nil {
+		t.Errorf("expecting no returned error, but got %s", err.Error())
+	}
+
+	if len(catched) != 1 || catched[0].Error() != "can't add 6" {
+		t.Errorf("only the error of s.Add should fall through, but got: %v", catched)
+	}
+}
+
+func TestInheritErrHandler(t *testing.T) {
+	s := &S{4}
+	sub := func() *Queue { return Add(s.Add, 6).Add(s.Add, 1) }
+
+	err := OnError(IGNORE).Sub(sub()).Run()
+	if err != nil {
+		t.Errorf("expecting no returned error, but got %s", err.Error())
+	}
+
+	if s.Get() != 4 {
+		t.Errorf("isolated sub queue should stop on error, expecting 4, but got %d", s.Get())
+	}
+
+	err = OnError(IGNORE).Sub(sub().SetInheritance(Inherit)).Run()
+	if err != nil {
+		t.Errorf("expecting no returned error, but got %s", err.Error())
+	}
+
+	if s.Get() != 5 {
+		t.Errorf("wrong value, expecting 5, but got %d", s.Get())
+	}
+
+	OnError(IGNORE).Sub(sub().SetInheritance(Inherit).OnError(STOP)).Run()
+	if s.Get() != 5 {
+		t.Errorf("own error handler should be used, expecting 5, but got %d", s.Get())
+	}
+}
+
+func TestOverrideErrHandler(t *testing.T) {
+	s := &S{4}
+	sub := Add(s.Add, 6).Add(s.Add, 1).OnError(STOP).SetInheritance(Override)
+
+	err := OnError(IGNORE).Sub(sub).Run()
+	if err != nil {
+		t.Errorf("expecting no returned error, but got %s", err.Error())
+	}
+
+	if s.Get() != 5 {
+		t.Errorf("error handler of the parent should be used, expecting 5, but got %d", s.Get())
+	}
+
+	// not nested: the own error handler is used
+	err = sub.Run()
+	if err == nil {
+		t.Errorf("expecting returned error, but got none")
+	}
+}
+
+func TestInheritInRunArgument(t *testing.T) {
+	s := &S{4}
+	err := OnError(IGNORE).Add(
+		s.Set, Run(Add(Value, 6).Add(s.Add, PIPE).Add(Value, 8).SetInheritance(Inherit)),
+	).Run()
+
+	if err != nil {
+		t.Errorf("expecting no returned error, but got %s", err.Error())
+	}
+
+	if s.Get() != 8 {
+		t.Errorf("wrong value, expecting 8, but got %d", s.Get())
+	}
+}
+
+func TestErrHandlerAt(t *testing.T) {
+	step := ErrHandlerFunc(func(err error) error { return nil })
+
+	q := OnError(IGNORE).
+		AddNamed("a", Value, 1).
+		AddNamed("b", Value, 2).
+		TeeAndRun(
+			AddNamed("inherited", Ok).SetInheritance(Inherit),
+			AddNamed("isolated", Ok),
+		).
+		Add(Value, CallNamed("c", Value, 3).OnError(step)).
+		OnErrorAt("b", step)
+
+	tests := []struct {
+		name     string
+		expected ErrHandler
+	}{
+		{"a", IGNORE},
+		{"b", step},
+		{"c", step},
+		{"inherited", IGNORE},
+		{"isolated", STOP},
+		{"missing", nil},
+	}
+
+	for _, tt := range tests {
+		got := q.ErrHandlerAt(tt.name)
+		if fmt.Sprintf("%p", got) != fmt.Sprintf("%p", tt.expected) {
+			t.Errorf("ErrHandlerAt(%#v) should be %p, but is %p", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestErrHandlerAtCycle(t *testing.T) {
+	q := OnError(IGNORE).Add(set, "a")
+	inner := Add(appendString, PIPE).SetInheritance(Inherit)
+	q.Sub(inner).AddNamed("after", read)
+	inner.Add(appendString, Run(q))
+
+	if h := q.ErrHandlerAt("missing"); h != nil {
+		t.Errorf("ErrHandlerAt should return nil, but got %p", h)
+	}
+	if h := q.ErrHandlerAt("after"); fmt.Sprintf("%p", h) != fmt.Sprintf("%p", IGNORE) {
+		t.Errorf("ErrHandlerAt should return IGNORE, but got %p", h)
+	}
+}
@@ -2,7 +2,10 @@ package queue
 
 // Error returned if a function is not valid
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type InvalidFunc struct {
 	// position of the function in the queue
@@ -64,6 +67,9 @@ type CallPanic struct {
 
 	// name of the function call, if it is named
 	Name string
+
+	// path of the call, if the run has a debugger, recorder or replayer (see SetDebugger())
+	Path StepPath
 }
 
 func (c CallPanic) Error() string {
@@ -74,3 +80,189 @@ func (c CallPanic) Error() string {
 	return fmt.Sprintf("[%d] %#v function %#v panicked (was called with %#v):\n\t%s",
 		c.Position, c.Name, c.Type, c.Params, c.ErrorMessage)
 }
+
+// Error returned if a function with the failure convention FailOnFalse returned false (see FailOn())
+type NotOK struct {
+	// position of the function in the queue
+	Position int
+
+	// type signature of the function
+	Type string
+
+	// name of the function call, if it is named
+	Name string
+
+	// path of the call, if the run has a debugger, recorder or replayer (see SetDebugger())
+	Path StepPath
+}
+
+func (n NotOK) Error() string {
+	if n.Name == "" {
+		return fmt.Sprintf("[%d] function %#v returned not ok", n.Position, n.Type)
+	}
+	return fmt.Sprintf("[%d] %#v function %#v returned not ok", n.Position, n.Name, n.Type)
+}
+
+// Error passed to the error handler, if a value could not be closed automatically (see AutoClose())
+type CloseError struct {
+	// type of the value
+	Type string
+
+	// error returned by closing the value
+	Err error
+}
+
+func (c CloseError) Error() string {
+	return fmt.Sprintf("closing %s failed: %s", c.Type, c.Err)
+}
+
+// Error returned if all queues of a Race() or Hedge() failed
+type RaceError struct {
+	// errors of the queues, in the order of the queues
+	Errors []error
+}
+
+func (r RaceError) Error() string {
+	msg := fmt.Sprintf("all %d queues of the race failed:", len(r.Errors))
+	for i, err := range r.Errors {
+		msg += fmt.Sprintf("\n\t[%d] %s", i, err)
+	}
+	return msg
+}
+
+// Error returned if a call or tee with the given name could not be found
+type StepNotFound struct {
+	// name of the call
+	Name string
+}
+
+func (s StepNotFound) Error() string {
+	return fmt.Sprintf("no call or tee with name %#v", s.Name)
+}
+
+// Warning is a problem reported by CheckStrict() that does not break a run
+type Warning struct {
+	// position of the function in the queue
+	Position int
+
+	// warning message
+	Message string
+
+	// name of the function call, if it is named
+	Name string
+}
+
+func (w Warning) Error() string {
+	if w.Name == "" {
+		return fmt.Sprintf("[%d] warning: %s", w.Position, w.Message)
+	}
+	return fmt.Sprintf("[%d] %#v warning: %s", w.Position, w.Name, w.Message)
+}
+
+// CheckError is a problem found by CheckAll() or CheckStrict()
+type CheckError struct {
+	// path of the call in the queue
+	Path StepPath
+
+	// InvalidFunc, InvalidArgument, CycleError or Warning
+	Err error
+}
+
+func (c CheckError) Error() string {
+	return fmt.Sprintf("%s: %s", c.Path, c.Err)
+}
+
+// CheckErrors are all problems found by CheckAll() or CheckStrict()
+type CheckErrors []CheckError
+
+func (c CheckErrors) Error() string {
+	msgs := make([]string, len(c))
+	for i, e := range c {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Error returned by Check() if a queue is nested inside itself (via Sub(), Run(), Fallback(),
+// Race() or TeeAndRun() etc.), so that a run would never end
+type CycleError struct {
+	// path of the queue that is nested inside itself, empty for the checked queue
+	Start StepPath
+
+	// path where the queue is nested again and closes the cycle
+	Path StepPath
+
+	// name of the queue, if it is named
+	Name string
+}
+
+func (c CycleError) Error() string {
+	if c.Start == "" {
+		return fmt.Sprintf("queue %#v is nested in itself at %s", c.Name, c.Path)
+	}
+	return fmt.Sprintf("queue %#v at %s is nested in itself at %s", c.Name, c.Start, c.Path)
+}
+
+// Error returned if the queues of a run are nested deeper than the max depth (see SetMaxDepth())
+type MaxDepthExceeded struct {
+	// max nesting depth of the run
+	MaxDepth int
+
+	// name of the queue that would exceed the max depth, if it is named
+	Name string
+}
+
+func (m MaxDepthExceeded) Error() string {
+	return fmt.Sprintf("queue %#v exceeds the max nesting depth of %d", m.Name, m.MaxDepth)
+}
+
+// Error returned if a run is aborted at a breakpoint of a Debugger
+type Aborted struct {
+	// path of the call or tee where the run was aborted
+	Path StepPath
+
+	// name of the call or tee, if it is named
+	Name string
+}
+
+func (a Aborted) Error() string {
+	if a.Name == "" {
+		return fmt.Sprintf("run aborted at %s", a.Path)
+	}
+	return fmt.Sprintf("run aborted at %s %#v", a.Path, a.Name)
+}
+
+// Error reported if a replayed call differs from the recorded trace (see Replayer)
+type ReplayMismatch struct {
+	// path of the call or tee
+	Path StepPath
+
+	// name of the call or tee, if it is named
+	Name string
+
+	// error message
+	ErrorMessage string
+}
+
+func (r ReplayMismatch) Error() string {
+	if r.Name == "" {
+		return fmt.Sprintf("replay mismatch at %s: %s", r.Path, r.ErrorMessage)
+	}
+	return fmt.Sprintf("replay mismatch at %s %#v: %s", r.Path, r.Name, r.ErrorMessage)
+}
+
+// Error returned if a QueueDef can't be loaded or a queue can't be marshalled
+type DefinitionError struct {
+	// path of the call in the queue
+	Path StepPath
+
+	// error message
+	ErrorMessage string
+}
+
+func (d DefinitionError) Error() string {
+	if d.Path == "" {
+		return fmt.Sprintf("invalid queue definition: %s", d.ErrorMessage)
+	}
+	return fmt.Sprintf("invalid queue definition at %s: %s", d.Path, d.ErrorMessage)
+}
@@ -0,0 +1,152 @@
+package queue
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// explainNode is a validated call or queue with its nested calls and queues
+type explainNode struct {
+	stepReport
+	children []*explainNode
+}
+
+// Explain writes a human readable plan of the queue to w, as it would be run
+// with values of the given input types.
+//
+// For every call and tee, the plan shows its StepPath, name and function type,
+// the types of the arguments it receives (and where they come from: literal,
+// PIPE, CTX, Call, Run, Fallback or Race) with the conversions of the Converter
+// (see SetConverter()), the types it passes on and the error handler that handles
+// its errors. Nested calls and queues are indented.
+//
+// Problems that Check() would report are marked inline with "!!" and the plan
+// continues with the best guess for the types.
+func (q *Queue) Explain(w io.Writer, inputTypes ...reflect.Type) error {
+	root := &explainNode{}
+	stack := []*explainNode{root}
+
+	ch := &checker{all: true}
+	ch.report = func(r stepReport) {
+		if r.begin {
+			stack = append(stack, &explainNode{})
+			return
+		}
+		n := stack[len(stack)-1]
+		n.stepReport = r
+		stack = stack[:len(stack)-1]
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, n)
+	}
+	ch.queue(q, "", inputTypes, nil)
+
+	var bf bytes.Buffer
+	for _, n := range root.children {
+		n.write(&bf, "")
+	}
+	_, err := bf.WriteTo(w)
+	return err
+}
+
+func (n *explainNode) write(bf *bytes.Buffer, indent string) {
+	if n.c == nil {
+		n.writeQueue(bf, indent)
+		return
+	}
+
+	fmt.Fprintf(bf, "%s[%s]", indent, n.path)
+	if n.c.name != "" {
+		fmt.Fprintf(bf, " %#v", n.c.name)
+	}
+	switch n.kind {
+	case StepCall, StepTee:
+		fmt.Fprintf(bf, " %s", n.c.function.Type())
+		if n.kind == StepTee {
+			fmt.Fprintf(bf, " (tee)")
+		}
+	default:
+		fmt.Fprintf(bf, " %s", n.kind)
+	}
+	bf.WriteString("\n")
+
+	inner := indent + "\t"
+	fmt.Fprintf(bf, "%s\targs:    %s\n", indent, explainArgs(n.args, n.sources, n.conv))
+	if n.kind != StepTee && n.kind != StepTeeRun && n.kind != StepTeeFallback {
+		fmt.Fprintf(bf, "%s\treturns: %s\n", indent, explainTypes(n.returns))
+	}
+	fmt.Fprintf(bf, "%s\terrors:  %s\n", indent, errHandlerName(n.errHandler))
+	if n.err != nil {
+		fmt.Fprintf(bf, "%s\t!! %s\n", indent, strings.Replace(n.err.Error(), "\n\t", " ", -1))
+	}
+
+	for _, child := range n.children {
+		child.write(bf, inner)
+	}
+}
+
+func (n *explainNode) writeQueue(bf *bytes.Buffer, indent string) {
+	if n.path == "" {
+		fmt.Fprintf(bf, "%squeue %#v\n", indent, queueLabel(n.q))
+	} else {
+		fmt.Fprintf(bf, "%s[%s] queue %#v\n", indent, n.path, queueLabel(n.q))
+	}
+	fmt.Fprintf(bf, "%s\targs:    %s\n", indent, explainTypes(n.args))
+	fmt.Fprintf(bf, "%s\terrors:  %s (%s)\n", indent, errHandlerName(n.errHandler), n.q.inheritance)
+	if n.err != nil {
+		fmt.Fprintf(bf, "%s\t!! %s\n", indent, n.err)
+	}
+	for _, child := range n.children {
+		child.write(bf, indent+"\t")
+	}
+	fmt.Fprintf(bf, "%s\treturns: %s\n", indent, explainTypes(n.returns))
+}
+
+func explainTypes(types []reflect.Type) string {
+	if len(types) == 0 {
+		return "-"
+	}
+	s := make([]string, len(types))
+	for i, t := range types {
+		s[i] = t.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+func explainArgs(types []reflect.Type, sources []ArgKind, conv []*conversion) string {
+	if len(types) == 0 {
+		return "-"
+	}
+	s := make([]string, len(types))
+	for i, t := range types {
+		s[i] = t.String()
+		if i < len(sources) {
+			s[i] += " (" + sources[i].String() + ")"
+		}
+		if i < len(conv) && conv[i] != nil {
+			s[i] += " -> " + conv[i].String()
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+// errHandlerName returns a readable name of the error handler h
+func errHandlerName(h ErrHandler) string {
+	if ft, ok := h.(fallThrough); ok {
+		return "FallThrough(" + errHandlerName(ft.ErrHandler) + ")"
+	}
+	if f, ok := h.(ErrHandlerFunc); ok {
+		p := reflect.ValueOf(f).Pointer()
+		switch p {
+		case reflect.ValueOf(STOP).Pointer():
+			return "STOP"
+		case reflect.ValueOf(IGNORE).Pointer():
+			return "IGNORE"
+		case reflect.ValueOf(PANIC).Pointer():
+			return "PANIC"
+		}
+	}
+	return fmt.Sprintf("%T", h)
+}
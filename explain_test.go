@@ -0,0 +1,83 @@
+package queue
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	s := &S{}
+	q := AddNamed("atoi", strconv.Atoi, PIPE).
+		TeeNamed("log", appendString, PIPE).
+		Add(s.Set, Call(strconv.Atoi, PIPE)).
+		Add(appendString, Fallback(Add(read).OnError(IGNORE))).
+		OnErrorAt("log", IGNORE).
+		SetName("main")
+
+	var bf bytes.Buffer
+	err := q.Explain(&bf, reflect.TypeOf(""))
+
+	if err != nil {
+		t.Fatalf("expecting no error but got: %s", err)
+	}
+
+	expected := `queue "main"
+	args:    string
+	errors:  STOP (Isolate)
+	[0] "atoi" func(string) (int, error)
+		args:    string (PIPE)
+		returns: int
+		errors:  STOP
+	[0/tee0] "log" func(...string) error (tee)
+		args:    int (PIPE)
+		errors:  IGNORE
+		!! [0] "log" function "func(...string) error" gets invalid argument: 1. argument  is a "int" but should be a "string"
+	[1] func(int) error
+		args:    int (Call)
+		returns: -
+		errors:  STOP
+		[1/arg0] func(string) (int, error)
+			args:    int (PIPE)
+			returns: int
+			errors:  STOP
+			!! [100] function "func(string) (int, error)" gets invalid argument: 1. argument is a "int" but should be a "string"
+	[2] func(...string) error
+		args:    string (Fallback)
+		returns: -
+		errors:  STOP
+		[2/arg0/fallback0] queue "queue"
+			args:    -
+			errors:  IGNORE (Isolate)
+			[2/arg0/fallback0/0] func() string
+				args:    -
+				returns: string
+				errors:  IGNORE
+			returns: string
+	returns: -
+`
+
+	if bf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, bf.String())
+	}
+}
+
+func TestErrHandlerName(t *testing.T) {
+	tests := []struct {
+		handler  ErrHandler
+		expected string
+	}{
+		{STOP, "STOP"},
+		{IGNORE, "IGNORE"},
+		{PANIC, "PANIC"},
+		{FallThrough(IGNORE), "FallThrough(IGNORE)"},
+		{ErrHandlerFunc(func(err error) error { return err }), "queue.ErrHandlerFunc"},
+	}
+
+	for _, tt := range tests {
+		if got := errHandlerName(tt.handler); got != tt.expected {
+			t.Errorf("errHandlerName should be %#v, but is %#v", tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package queue
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// Failure is the convention of a function to report that it failed with its last returned value.
+// The last value is not piped to the next call, if it reports failures.
+type Failure int
+
+const (
+	// FailOnError treats a last returned value of a type that implements error as failure,
+	// if it is not nil (or not the zero value for types that can't be nil). It is the default.
+	FailOnError Failure = iota + 1
+
+	// FailOnFalse treats a last returned bool as failure, if it is false, like the
+	// "comma ok" of a map lookup. The failure is handled as NotOK error by the error handlers.
+	// A last returned error is handled like with FailOnError.
+	FailOnFalse
+)
+
+var failureNames = [...]string{"", "FailOnError", "FailOnFalse"}
+
+func (f Failure) String() string {
+	if f <= 0 || int(f) >= len(failureNames) {
+		return "Failure(" + strconv.Itoa(int(f)) + ")"
+	}
+	return failureNames[f]
+}
+
+// FailOn sets the failure convention of the calls and tees of q that have none of their own
+// and may be chained. Nested queues have their own failure convention.
+func (q *Queue) FailOn(f Failure) *Queue {
+	q.failure = f
+	return q
+}
+
+// FailOnAt sets the failure convention of every call and tee of q with the given name
+// (including calls passed as arguments via CallNamed()) and may be chained. Like OnErrorAt(),
+// it applies to calls that are added later as well and replaces the FailOn() of the call.
+func (q *Queue) FailOnAt(name string, f Failure) *Queue {
+	if q.failuresAt == nil {
+		q.failuresAt = map[string]Failure{}
+	}
+	q.failuresAt[name] = f
+	return q
+}
+
+// FailOn sets the failure convention of the call that is used instead of the one of the queue
+func (c *call) FailOn(f Failure) *call {
+	c.failure = f
+	return c
+}
+
+// failureOf returns the failure convention of the call c of q
+func (q *Queue) failureOf(c *call) Failure {
+	if f := q.failuresAt[c.name]; f != 0 {
+		return f
+	}
+	switch {
+	case c.failure != 0:
+		return c.failure
+	case q.failure != 0:
+		return q.failure
+	}
+	return FailOnError
+}
+
+// reportsFailure reports, if a last returned value of type t reports failures with the convention f
+func reportsFailure(t reflect.Type, f Failure) bool {
+	return t.Implements(errorType) || (f == FailOnFalse && t.Kind() == reflect.Bool)
+}
+
+// failed returns the error of the last returned value v that reports failures (see reportsFailure())
+// and nil, if it reports none
+func failed(v reflect.Value) error {
+	if v.Kind() == reflect.Bool {
+		if v.Bool() {
+			return nil
+		}
+		return NotOK{}
+	}
+	if isNilable(v) && v.IsNil() || !isNilable(v) && v.IsZero() {
+		return nil
+	}
+	return v.Interface().(error)
+}
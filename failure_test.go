@@ -0,0 +1,115 @@
+package queue
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+type failErr struct{ msg string }
+
+func (f *failErr) Error() string { return f.msg }
+
+type valueErr string
+
+func (v valueErr) Error() string { return string(v) }
+
+func TestFailOnFalse(t *testing.T) {
+	m := map[string]int{"a": 1}
+	lookup := func(key string) (int, bool) {
+		v, ok := m[key]
+		return v, ok
+	}
+	var got []int
+	collect := func(i int) { got = append(got, i) }
+
+	q := New().FailOn(FailOnFalse).
+		Add(lookup, "a").
+		Add(collect, PIPE)
+	if err := q.Check(); err != nil {
+		t.Fatalf("Check should accept, but got %s", err)
+	}
+	if err := q.Run(); err != nil {
+		t.Errorf("Run should work, but got %s", err)
+	}
+	if !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("expected [1], but got %v", got)
+	}
+
+	got = nil
+	q = New().
+		AddNamed("lookup", lookup, "b").
+		Add(collect, PIPE).
+		FailOnAt("lookup", FailOnFalse)
+	err := q.Run()
+	expected := NotOK{Position: 0, Type: "func(string) (int, bool)", Name: "lookup"}
+	if err != expected {
+		t.Errorf("expected %#v, but got %#v", expected, err)
+	}
+	if len(got) != 0 {
+		t.Errorf("collect should not be called")
+	}
+
+	// without FailOnFalse, the bool is piped
+	if err := Add(lookup, "b").Add(collect, PIPE).Check(); err == nil {
+		t.Errorf("Check should reject the piped bool")
+	}
+
+	// the failure convention of the call counts
+	q = New().FailOn(FailOnFalse).
+		Add(collect, Call(lookup, "b").FailOn(FailOnError))
+	if err := q.Check(); err == nil {
+		t.Errorf("Check should reject the piped bool")
+	}
+
+	// errors are still failures
+	q = New().FailOn(FailOnFalse).Add(os.Open, "/does/not/exist")
+	if _, ok := q.Run().(*os.PathError); !ok {
+		t.Errorf("expecting *os.PathError, but got %v", q.Run())
+	}
+}
+
+func TestCustomErrorTypes(t *testing.T) {
+	var got []string
+	collect := func(s string) { got = append(got, s) }
+	ptr := func(fail bool) (string, *failErr) {
+		if fail {
+			return "", &failErr{"ptr failed"}
+		}
+		return "ptr", nil
+	}
+	val := func(fail bool) (string, valueErr) {
+		if fail {
+			return "", "val failed"
+		}
+		return "val", ""
+	}
+
+	tests := []struct {
+		fn  interface{}
+		msg string
+	}{
+		{ptr, "ptr failed"},
+		{val, "val failed"},
+	}
+
+	for _, test := range tests {
+		fn := test.fn
+		got = nil
+		q := Add(fn, false).Add(collect, PIPE)
+		if err := q.Check(); err != nil {
+			t.Errorf("%T: Check should accept, but got %s", fn, err)
+		}
+		if err := q.Run(); err != nil {
+			t.Errorf("%T: Run should work, but got %#v", fn, err)
+		}
+		if len(got) != 1 {
+			t.Errorf("%T: collect should be called once, but got %v", fn, got)
+		}
+
+		err := Add(fn, true).Add(collect, PIPE).Run()
+		if err == nil || err.Error() != test.msg {
+			t.Errorf("%T: expecting error, but got %#v", fn, err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package queue
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type fuzzInt int
+
+// fuzzTypes are the types of the generated parameters and returned values
+var fuzzTypes = []reflect.Type{
+	reflect.TypeOf(0),
+	reflect.TypeOf(""),
+	reflect.TypeOf(fuzzInt(0)),
+	reflect.TypeOf((*int)(nil)),
+	reflect.TypeOf((*interface{})(nil)).Elem(),
+	reflect.TypeOf((*error)(nil)).Elem(),
+	reflect.TypeOf((*fmt.Stringer)(nil)).Elem(),
+	reflect.TypeOf((*io.Reader)(nil)).Elem(),
+	reflect.TypeOf([]string{}),
+	reflect.TypeOf(map[string]int{}),
+}
+
+// fuzzValues are the literal arguments of the generated calls
+var fuzzValues = []interface{}{
+	nil,
+	1,
+	"a",
+	fuzzInt(2),
+	new(int),
+	(*int)(nil),
+	errors.New("e"),
+	bytes.NewBufferString("b"),
+	(*bytes.Buffer)(nil),
+	[]string{"s"},
+	[]string(nil),
+	map[string]int{},
+	PIPE,
+}
+
+// fuzzGen generates functions and arguments from the fuzzed data
+type fuzzGen struct {
+	data []byte
+
+	// nesting depth of the generated calls and queues
+	depth int
+}
+
+func (g *fuzzGen) next(n int) int {
+	if len(g.data) == 0 {
+		return 0
+	}
+	b := g.data[0]
+	g.data = g.data[1:]
+	return int(b) % n
+}
+
+func (g *fuzzGen) types(max int) []reflect.Type {
+	types := make([]reflect.Type, g.next(max+1))
+	for i := range types {
+		types[i] = fuzzTypes[g.next(len(fuzzTypes))]
+	}
+	return types
+}
+
+// fn returns a function of a random signature that returns zero values
+// and the types of the values it passes on
+func (g *fuzzGen) fn() (interface{}, string) {
+	ins, outs := g.types(3), g.types(2)
+	variadic := len(ins) > 0 && g.next(2) == 1
+	if variadic {
+		ins[len(ins)-1] = reflect.SliceOf(ins[len(ins)-1])
+	}
+	ftype := reflect.FuncOf(ins, outs, variadic)
+	fn := reflect.MakeFunc(ftype, func([]reflect.Value) []reflect.Value {
+		res := make([]reflect.Value, len(outs))
+		for i, t := range outs {
+			res[i] = reflect.New(t).Elem()
+		}
+		return res
+	})
+	return fn.Interface(), ftype.String()
+}
+
+// args returns random literal arguments, PIPE and nested calls
+func (g *fuzzGen) args() []interface{} {
+	args := make([]interface{}, g.next(5))
+	for i := range args {
+		k := g.next(len(fuzzValues) + 1)
+		if k < len(fuzzValues) || g.depth > 2 {
+			args[i] = fuzzValues[k%len(fuzzValues)]
+			continue
+		}
+		g.depth++
+		fn, _ := g.fn()
+		args[i] = Call(fn, g.args()...)
+		g.depth--
+	}
+	return args
+}
+
+// queue returns a queue of random calls and a description of it
+func (g *fuzzGen) queue() (*Queue, string) {
+	q := New()
+	var desc bytes.Buffer
+	for i, n := 0, 1+g.next(3); i < n; i++ {
+		if g.next(8) == 7 && g.depth < 2 {
+			g.depth++
+			sub, subDesc := g.queue()
+			g.depth--
+			q.Sub(sub)
+			fmt.Fprintf(&desc, "\n\tSub(%s\n\t)", subDesc)
+			continue
+		}
+		fn, ftype := g.fn()
+		args := g.args()
+		q.Add(fn, args...)
+		fmt.Fprintf(&desc, "\n\t%s %#v", ftype, args)
+	}
+	return q, desc.String()
+}
+
+// checkAndRun checks that Check() does not panic and that a run of a queue accepted by
+// Check() does not panic in reflect.Call
+func checkAndRun(t *testing.T, q *Queue, desc string) {
+	var checkErr error
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				t.Fatalf("Check panics with %v for %s", p, desc)
+			}
+		}()
+		checkErr = q.Check()
+	}()
+
+	if checkErr != nil {
+		return
+	}
+	if err := q.Run(); err != nil {
+		t.Fatalf("Check accepts, but Run fails with %v for %s", err, desc)
+	}
+}
+
+func FuzzCheckAndRun(f *testing.F) {
+	f.Add([]byte{})
+	// func() with nil
+	f.Add([]byte{0, 0, 0, 0, 1, 0})
+	// func(int) with nil
+	f.Add([]byte{0, 0, 1, 0, 0, 0, 1, 0})
+	// variadic func(...string) with nil
+	f.Add([]byte{0, 0, 1, 1, 0, 1, 1, 0})
+	// variadic func(...*int) with two nils
+	f.Add([]byte{0, 0, 1, 3, 0, 1, 2, 0, 0})
+	// variadic func(...*int) with (*int)(nil)
+	f.Add([]byte{0, 0, 1, 3, 0, 1, 1, 5})
+	// variadic func(...*int) with two (*int)(nil)
+	f.Add([]byte{0, 0, 1, 3, 0, 1, 2, 5, 5})
+	// func() fmt.Stringer piped into func(fmt.Stringer)
+	f.Add([]byte{1, 0, 0, 1, 6, 0, 0, 1, 6, 0, 0, 1, 12})
+	// Sub(func(*int) with (*int)(nil))
+	f.Add([]byte{0, 7, 0, 0, 1, 3, 0, 0, 1, 5})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		g := &fuzzGen{data: data}
+		q, desc := g.queue()
+		checkAndRun(t, q, desc)
+	})
+}
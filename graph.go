@@ -0,0 +1,329 @@
+package queue
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// edgeStyle is the style of an edge in the graph of a queue
+type edgeStyle int
+
+const (
+	// values are piped
+	edgePipe edgeStyle = iota
+	// run order without piped values
+	edgeOrder
+	// values are piped into a tee
+	edgeTee
+	// values are piped into or out of a fallback alternative
+	edgeFallback
+	// values are piped into or out of a race alternative
+	edgeRace
+)
+
+type graphNode struct {
+	id    string
+	label string
+}
+
+type graphEdge struct {
+	from, to string
+	label    string
+	style    edgeStyle
+}
+
+type graphCluster struct {
+	id       string
+	label    string
+	nodes    []graphNode
+	clusters []*graphCluster
+}
+
+// graph is built from a queue and written as DOT or Mermaid
+type graph struct {
+	root  *graphCluster
+	edges []graphEdge
+	ids   int
+
+	// queues that are currently walked, to stop on cycles
+	walking map[*Queue]bool
+}
+
+func newGraph(q *Queue) *graph {
+	g := &graph{walking: map[*Queue]bool{}}
+	g.root = &graphCluster{label: queueLabel(q)}
+	g.queue(g.root, q)
+	return g
+}
+
+func queueLabel(q *Queue) string {
+	if q.name != "" {
+		return q.name
+	}
+	return "queue"
+}
+
+func (g *graph) node(cl *graphCluster, label string) string {
+	g.ids++
+	id := fmt.Sprintf("n%d", g.ids)
+	cl.nodes = append(cl.nodes, graphNode{id, label})
+	return id
+}
+
+func (g *graph) cluster(parent *graphCluster, q *Queue) *graphCluster {
+	g.ids++
+	cl := &graphCluster{id: fmt.Sprintf("c%d", g.ids), label: queueLabel(q)}
+	parent.clusters = append(parent.clusters, cl)
+	return cl
+}
+
+func (g *graph) edge(from, to string, label string, style edgeStyle) {
+	g.edges = append(g.edges, graphEdge{from, to, label, style})
+}
+
+// ends are the nodes of a step or queue that receive the piped values (entries)
+// and the node that returns the values (exit)
+type ends struct {
+	// entries with an own label or style (e.g. alternatives) are kept when connected
+	entries []graphEdge
+	exit    string
+
+	// false, if no piped values are received. then entries
+	// are the nodes that are run first
+	pipes bool
+}
+
+// entry returns the entries of a single node
+func entry(id string) []graphEdge {
+	return []graphEdge{{to: id}}
+}
+
+// connect adds edges from the node from to the entries of e
+func (g *graph) connect(from string, e ends, label string, style edgeStyle) {
+	if !e.pipes && style == edgePipe {
+		style = edgeOrder
+		label = ""
+	}
+	for _, in := range e.entries {
+		if in.label != "" {
+			g.edge(from, in.to, in.label, in.style)
+			continue
+		}
+		g.edge(from, in.to, label, style)
+	}
+}
+
+// nested adds the queue q as a cluster inside cl
+func (g *graph) nested(cl *graphCluster, q *Queue) ends {
+	if g.walking[q] {
+		id := g.node(cl, "cycle: "+queueLabel(q))
+		return ends{entry(id), id, true}
+	}
+	return g.queue(g.cluster(cl, q), q)
+}
+
+// queue adds the steps of q to cl
+func (g *graph) queue(cl *graphCluster, q *Queue) (e ends) {
+	g.walking[q] = true
+	defer delete(g.walking, q)
+
+	if len(q.calls) == 0 {
+		id := g.node(cl, "(empty)")
+		return ends{entry(id), id, true}
+	}
+
+	for i, c := range q.calls {
+		st := g.step(cl, c)
+		if i == 0 {
+			e = st
+		} else {
+			g.connect(e.exit, st, "PIPE", edgePipe)
+		}
+		e.exit = st.exit
+
+		for _, tee := range q.tees[i] {
+			g.tee(cl, tee, e.exit)
+		}
+	}
+	return
+}
+
+// chain adds the queues, so that each one gets the values of the previous one
+func (g *graph) chain(cl *graphCluster, qs []Queuer) (e ends) {
+	for k, qe := range qs {
+		n := g.nested(cl, qe.Queue())
+		if k == 0 {
+			e = n
+		} else {
+			g.connect(e.exit, n, "PIPE", edgePipe)
+		}
+		e.exit = n.exit
+	}
+	return
+}
+
+// alternatives adds the queues of Fallback() or Race() that return their values to the node to
+func (g *graph) alternatives(cl *graphCluster, qs []Queuer, to string, label string, style edgeStyle) (entries []graphEdge) {
+	for k, qe := range qs {
+		n := g.nested(cl, qe.Queue())
+		l := fmt.Sprintf("%s %d", label, k)
+		for _, in := range n.entries {
+			entries = append(entries, graphEdge{to: in.to, label: l, style: style})
+		}
+		g.edge(n.exit, to, l, style)
+	}
+	return
+}
+
+// step adds the call c to cl
+func (g *graph) step(cl *graphCluster, c *call) (e ends) {
+	if qs := c.function.Interface(); c.function.Type() == queuersType && len(qs.([]Queuer)) > 0 {
+		return g.chain(cl, qs.([]Queuer))
+	}
+
+	label := c.name
+	if label == "" {
+		label = c.function.Type().String()
+	}
+	e.exit = g.node(cl, label)
+
+	for _, arg := range c.arguments {
+		switch a := arg.(type) {
+		case pipe:
+			e.entries = append(e.entries, entry(e.exit)...)
+		case *call:
+			n := g.step(cl, a)
+			g.edge(n.exit, e.exit, "Call", edgePipe)
+			if n.pipes {
+				e.entries = append(e.entries, n.entries...)
+			}
+		case callrun:
+			n := g.chain(cl, a)
+			if n.exit != "" {
+				g.edge(n.exit, e.exit, "Run", edgePipe)
+				e.entries = append(e.entries, n.entries...)
+			}
+		case callfallback:
+			e.entries = append(e.entries, g.alternatives(cl, a, e.exit, "fallback", edgeFallback)...)
+		case callrace:
+			e.entries = append(e.entries, g.alternatives(cl, a.queues, e.exit, "race", edgeRace)...)
+		}
+	}
+
+	if len(e.entries) == 0 {
+		e.entries = entry(e.exit)
+		return
+	}
+	e.pipes = true
+	return
+}
+
+// tee adds the tee c that gets the values returned by the node from
+func (g *graph) tee(cl *graphCluster, c *call, from string) {
+	if c.feed == 0 {
+		g.connect(from, g.step(cl, c), "tee", edgeTee)
+		return
+	}
+
+	style := edgeTee
+	label := "TeeAndRun"
+	if c.feed == feedFallback || c.feed == feedCheckFallback {
+		style = edgeFallback
+		label = "TeeAndFallback"
+	}
+	for k, qe := range c.feeded {
+		g.connect(from, g.nested(cl, qe.Queue()), fmt.Sprintf("%s %d", label, k), style)
+	}
+}
+
+// WriteDOT writes the graph of the queue in the DOT format of Graphviz to w.
+//
+// Every call and tee is a node, labeled with its name or (if unnamed) its
+// function type. Nested queues are clusters. Solid edges show piped values,
+// dotted edges the run order without piped values, dashed edges fallback
+// alternatives and bold edges race alternatives.
+func (q *Queue) WriteDOT(w io.Writer) error {
+	g := newGraph(q)
+	var bf bytes.Buffer
+	fmt.Fprintf(&bf, "digraph %s {\n\tlabel=%s;\n\tnode [shape=box];\n", dotQuote(g.root.label), dotQuote(g.root.label))
+	writeDOTCluster(&bf, g.root, "\t")
+	for _, e := range g.edges {
+		attrs := []string{}
+		if e.label != "" {
+			attrs = append(attrs, "label="+dotQuote(e.label))
+		}
+		switch e.style {
+		case edgeOrder:
+			attrs = append(attrs, "style=dotted")
+		case edgeTee:
+			attrs = append(attrs, "arrowhead=odiamond")
+		case edgeFallback:
+			attrs = append(attrs, "style=dashed")
+		case edgeRace:
+			attrs = append(attrs, "style=bold")
+		}
+		fmt.Fprintf(&bf, "\t%s -> %s [%s];\n", e.from, e.to, strings.Join(attrs, ", "))
+	}
+	bf.WriteString("}\n")
+	_, err := bf.WriteTo(w)
+	return err
+}
+
+func writeDOTCluster(bf *bytes.Buffer, cl *graphCluster, indent string) {
+	for _, n := range cl.nodes {
+		fmt.Fprintf(bf, "%s%s [label=%s];\n", indent, n.id, dotQuote(n.label))
+	}
+	for _, sub := range cl.clusters {
+		fmt.Fprintf(bf, "%ssubgraph cluster_%s {\n%s\tlabel=%s;\n", indent, sub.id, indent, dotQuote(sub.label))
+		writeDOTCluster(bf, sub, indent+"\t")
+		fmt.Fprintf(bf, "%s}\n", indent)
+	}
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.Replace(strings.Replace(s, `\`, `\\`, -1), `"`, `\"`, -1) + `"`
+}
+
+// WriteMermaid writes the graph of the queue as Mermaid flowchart to w.
+// The graph is the same as the one of WriteDOT(). Dotted edges show the run order
+// without piped values and fallback alternatives, thick edges race alternatives.
+func (q *Queue) WriteMermaid(w io.Writer) error {
+	g := newGraph(q)
+	var bf bytes.Buffer
+	fmt.Fprintf(&bf, "flowchart TD\n")
+	writeMermaidCluster(&bf, g.root, "\t")
+	for _, e := range g.edges {
+		arrow := "-->"
+		switch e.style {
+		case edgeOrder, edgeFallback:
+			arrow = "-.->"
+		case edgeTee:
+			arrow = "--o"
+		case edgeRace:
+			arrow = "==>"
+		}
+		if e.label != "" {
+			arrow += "|" + mermaidQuote(e.label) + "|"
+		}
+		fmt.Fprintf(&bf, "\t%s %s %s\n", e.from, arrow, e.to)
+	}
+	_, err := bf.WriteTo(w)
+	return err
+}
+
+func writeMermaidCluster(bf *bytes.Buffer, cl *graphCluster, indent string) {
+	for _, n := range cl.nodes {
+		fmt.Fprintf(bf, "%s%s[%s]\n", indent, n.id, mermaidQuote(n.label))
+	}
+	for _, sub := range cl.clusters {
+		fmt.Fprintf(bf, "%ssubgraph %s [%s]\n", indent, sub.id, mermaidQuote(sub.label))
+		writeMermaidCluster(bf, sub, indent+"\t")
+		fmt.Fprintf(bf, "%send\n", indent)
+	}
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.Replace(s, `"`, "#quot;", -1) + `"`
+}
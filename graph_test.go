@@ -0,0 +1,116 @@
+package queue
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func graphTestQueue() *Queue {
+	return AddNamed("set", set, "9").
+		Add(read).
+		TeeNamed("log", appendString, PIPE).
+		Add(setInt, Call(strconv.Atoi, PIPE)).
+		Add(appendString, Fallback(AddNamed("a", read), AddNamed("b", read))).
+		SetName("main")
+}
+
+func TestWriteDOT(t *testing.T) {
+	var bf bytes.Buffer
+	err := graphTestQueue().WriteDOT(&bf)
+
+	if err != nil {
+		t.Fatalf("expecting no error but got: %s", err)
+	}
+
+	expected := `digraph "main" {
+	label="main";
+	node [shape=box];
+	n1 [label="set"];
+	n2 [label="func() string"];
+	n3 [label="log"];
+	n4 [label="func(int) error"];
+	n5 [label="func(string) (int, error)"];
+	n6 [label="func(...string) error"];
+	subgraph cluster_c7 {
+		label="queue";
+		n8 [label="a"];
+	}
+	subgraph cluster_c9 {
+		label="queue";
+		n10 [label="b"];
+	}
+	n1 -> n2 [style=dotted];
+	n2 -> n3 [label="tee", arrowhead=odiamond];
+	n5 -> n4 [label="Call"];
+	n2 -> n5 [label="PIPE"];
+	n8 -> n6 [label="fallback 0", style=dashed];
+	n10 -> n6 [label="fallback 1", style=dashed];
+	n4 -> n8 [label="fallback 0", style=dashed];
+	n4 -> n10 [label="fallback 1", style=dashed];
+}
+`
+
+	if bf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, bf.String())
+	}
+}
+
+func TestWriteMermaid(t *testing.T) {
+	var bf bytes.Buffer
+	err := graphTestQueue().WriteMermaid(&bf)
+
+	if err != nil {
+		t.Fatalf("expecting no error but got: %s", err)
+	}
+
+	expected := `flowchart TD
+	n1["set"]
+	n2["func() string"]
+	n3["log"]
+	n4["func(int) error"]
+	n5["func(string) (int, error)"]
+	n6["func(...string) error"]
+	subgraph c7 ["queue"]
+		n8["a"]
+	end
+	subgraph c9 ["queue"]
+		n10["b"]
+	end
+	n1 -.-> n2
+	n2 --o|"tee"| n3
+	n5 -->|"Call"| n4
+	n2 -->|"PIPE"| n5
+	n8 -.->|"fallback 0"| n6
+	n10 -.->|"fallback 1"| n6
+	n4 -.->|"fallback 0"| n8
+	n4 -.->|"fallback 1"| n10
+`
+
+	if bf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, bf.String())
+	}
+}
+
+func TestGraphNested(t *testing.T) {
+	sub := Add(appendString, PIPE).SetName("sub")
+	q := Add(read).Sub(sub).TeeAndFallback(sub).Add(set, Race(sub, sub)).Add(set, Run(sub))
+	q.Sub(q)
+
+	var bf bytes.Buffer
+	q.WriteDOT(&bf)
+	dot := bf.String()
+
+	for _, s := range []string{
+		`label="sub";`,
+		`label="TeeAndFallback 0", style=dashed`,
+		`label="race 1", style=bold`,
+		`label="Run"`,
+		`label="cycle: queue"`,
+	} {
+		if !strings.Contains(dot, s) {
+			t.Errorf("graph should contain %#v, but is:\n%s", s, dot)
+		}
+	}
+}
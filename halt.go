@@ -0,0 +1,43 @@
+package queue
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Halt may be returned as error by a function or tee to stop the queue
+// successfully. It is recognized (also when wrapped, see errors.Is()) before
+// the error handler is called.
+//
+// The non error values returned alongside Halt become the final values
+// of the queue, so a parent queue (see Sub(), Run(), Fallback()) continues
+// with them.
+var Halt = errors.New("queue halted")
+
+// Return works like Halt, but the given values become the final values
+// of the queue. errors.Is() reports the returned error as Halt.
+func Return(values ...interface{}) error {
+	return &returnError{values: values}
+}
+
+// returnError is the error returned by Return()
+type returnError struct {
+	values []interface{}
+}
+
+func (r *returnError) Error() string { return Halt.Error() }
+
+func (r *returnError) Is(target error) bool { return target == Halt }
+
+// isHalt checks if err is Halt or Return() and returns the
+// final values of the queue
+func isHalt(err error, piped []reflect.Value) (returns []reflect.Value, ok bool) {
+	var r *returnError
+	if errors.As(err, &r) {
+		return toValues(r.values), true
+	}
+	if errors.Is(err, Halt) {
+		return piped, true
+	}
+	return nil, false
+}
@@ -0,0 +1,172 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func cacheLookup(cache map[string]int, key string) (int, error) {
+	if v, has := cache[key]; has {
+		return v, Halt
+	}
+	return 0, nil
+}
+
+func TestHalt(t *testing.T) {
+	var got int
+	cache := map[string]int{"a": 42}
+	expensive := 0
+
+	lookup := func(key string) error {
+		return Add(
+			cacheLookup, cache, key,
+		).Add(
+			func() string { expensive++; return "7" },
+		).Add(
+			strconv.Atoi, PIPE,
+		).Add(
+			Set, &got, PIPE,
+		).Run()
+	}
+
+	err := lookup("a")
+	if err != nil {
+		t.Errorf("expecting no error but got: %s", err)
+	}
+
+	if expensive != 0 {
+		t.Errorf("expensive call should be skipped, but was called %d times", expensive)
+	}
+
+	err = lookup("b")
+	if err != nil {
+		t.Errorf("expecting no error but got: %s", err)
+	}
+
+	if got != 7 || expensive != 1 {
+		t.Errorf("queue should run until the end, got: %d, expensive: %d", got, expensive)
+	}
+}
+
+func TestHaltSkipsErrHandler(t *testing.T) {
+	called := false
+	handler := ErrHandlerFunc(func(err error) error {
+		called = true
+		return err
+	})
+
+	result = ""
+	err := OnError(handler).Add(
+		func() error { return Halt },
+	).Add(set, "a").Run()
+
+	if err != nil {
+		t.Errorf("expecting no error but got: %s", err)
+	}
+
+	if called {
+		t.Errorf("error handler should not be called for Halt")
+	}
+
+	if result != "" {
+		t.Errorf("result should be empty, but is: %#v", result)
+	}
+}
+
+func TestHaltSub(t *testing.T) {
+	var got int
+	cache := map[string]int{"a": 42}
+
+	cached := Add(cacheLookup, cache, PIPE).Add(func() int { return 0 })
+
+	err := Add(
+		Value, "a",
+	).Sub(
+		cached,
+	).Add(
+		Set, &got, PIPE,
+	).Run()
+
+	if err != nil {
+		t.Errorf("expecting no error but got: %s", err)
+	}
+
+	if got != 42 {
+		t.Errorf("got should be 42, but is: %d", got)
+	}
+}
+
+func TestReturn(t *testing.T) {
+	var got string
+
+	err := Add(
+		set, "x",
+	).Add(
+		Set, &got, Run(
+			Add(func() error { return Return("returned") }).Add(Value, "not reached"),
+		),
+	).CheckAndRun()
+
+	if err != nil {
+		t.Errorf("expecting no error but got: %s", err)
+	}
+
+	if got != "returned" {
+		t.Errorf("got should be %#v, but is: %#v", "returned", got)
+	}
+}
+
+func TestHaltTee(t *testing.T) {
+	result = ""
+	var got string
+
+	err := Add(
+		Value, "a",
+	).Tee(
+		func(string) error { return Halt }, PIPE,
+	).Add(set, "b").Run()
+
+	if err != nil {
+		t.Errorf("expecting no error but got: %s", err)
+	}
+
+	if result != "" {
+		t.Errorf("result should be empty, but is: %#v", result)
+	}
+
+	err = Add(Set, &got, Run(Add(Value, "a").Tee(func(string) error { return Halt }, PIPE).Add(Value, "b"))).Run()
+	if err != nil {
+		t.Errorf("expecting no error but got: %s", err)
+	}
+
+	if got != "a" {
+		t.Errorf("got should be %#v, but is: %#v", "a", got)
+	}
+}
+
+func TestHaltWrapped(t *testing.T) {
+	if !errors.Is(Return(), Halt) || errors.Is(errors.New("queue halted"), Halt) {
+		t.Errorf("only Halt and Return() should be Halt")
+	}
+
+	var got string
+	err := Add(
+		Set, &got, Run(
+			Add(func() (string, error) { return "a", fmt.Errorf("cached: %w", Halt) }).Add(Value, "b"),
+		),
+	).Run()
+	if err != nil || got != "a" {
+		t.Errorf("wrapped Halt should stop the queue, but got %#v and %v", got, err)
+	}
+
+	err = Add(
+		Set, &got, Run(
+			Add(func() error { return fmt.Errorf("cached: %w", Return("c")) }).Add(Value, "b"),
+		),
+	).Run()
+	if err != nil || got != "c" {
+		t.Errorf("wrapped Return() should stop the queue, but got %#v and %v", got, err)
+	}
+}
@@ -4,6 +4,7 @@ package queue
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"reflect"
 )
@@ -57,6 +58,11 @@ func argReturnStr(args ...interface{}) string {
 		if i > 0 {
 			fmt.Fprintf(&bf, ", ")
 		}
+		// contexts are not printed, since they might be changed concurrently
+		if _, isCtx := arg.(context.Context); isCtx {
+			fmt.Fprintf(&bf, "CTX")
+			continue
+		}
 		fmt.Fprintf(&bf, "%#v", arg)
 	}
 	return bf.String()
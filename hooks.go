@@ -0,0 +1,55 @@
+package queue
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type hooksKey struct{}
+
+// runHooks are the debugger, recorder and replayer of a run at the current step
+type runHooks struct {
+	debugger *Debugger
+	recorder *Recorder
+	replayer *Replayer
+
+	// path of the current step or nested queue
+	path StepPath
+
+	// the Aborted error, once a step was aborted (shared by all steps of the run)
+	aborted *atomic.Value
+}
+
+// enterHooks returns the context for a run of q with the debugger, recorder and replayer of q.
+// If the run already has hooks, because q is nested, the hooks of q are ignored.
+func (q *Queue) enterHooks(ctx context.Context) context.Context {
+	if q.debugger == nil && q.recorder == nil && q.replayer == nil {
+		return ctx
+	}
+	if _, ok := ctx.Value(hooksKey{}).(runHooks); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, hooksKey{}, runHooks{
+		debugger: q.debugger,
+		recorder: q.recorder,
+		replayer: q.replayer,
+		aborted:  &atomic.Value{},
+	})
+}
+
+// stepAt returns the context for the step with the given segment,
+// nested in the step of ctx. Without hooks ctx is returned.
+func stepAt(ctx context.Context, segment string, n int) context.Context {
+	h, ok := ctx.Value(hooksKey{}).(runHooks)
+	if !ok {
+		return ctx
+	}
+	h.path = h.path.childN(segment, n)
+	return context.WithValue(ctx, hooksKey{}, h)
+}
+
+// stepPath returns the path of the step of ctx, if the run has hooks
+func stepPath(ctx context.Context) StepPath {
+	h, _ := ctx.Value(hooksKey{}).(runHooks)
+	return h.path
+}
@@ -0,0 +1,39 @@
+package httpq_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"gopkg.in/go-on/queue.v2"
+	"gopkg.in/go-on/queue.v2/httpq"
+)
+
+func parseName(w http.ResponseWriter, r *http.Request) (string, error) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		return "", httpq.Status(http.StatusBadRequest, errors.New("missing name"))
+	}
+	return name, nil
+}
+
+func writeGreeting(w http.ResponseWriter, name string) error {
+	_, err := fmt.Fprintf(w, "hello %s", name)
+	return err
+}
+
+func Example() {
+	h := httpq.NewHandler(queue.New().
+		Add(parseName, queue.PIPE).
+		Add(writeGreeting, httpq.ResponseWriter(), queue.PIPE))
+
+	for _, url := range []string{"/?name=Tim", "/"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		fmt.Printf("%d %q\n", w.Code, w.Body.String())
+	}
+	// Output:
+	// 200 "hello Tim"
+	// 400 "missing name\n"
+}
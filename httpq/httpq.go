@@ -0,0 +1,287 @@
+// Package httpq serves queues of gopkg.in/go-on/queue.v2 as net/http handlers:
+//
+//   - Handler runs a queue for every request, with the http.ResponseWriter and the
+//     *http.Request piped into the first call and the context of the request as
+//     context of the run (see queue.RunContext())
+//   - ResponseWriter and Request pass them to later calls
+//   - errors that carry a status code (see StatusCoder and StatusError) are written
+//     as responses with that status, as JSON or plain text, other errors and panics
+//     as 500 Internal Server Error
+//
+// E.g.
+//
+//	h := httpq.NewHandler(queue.New().
+//		Add(parseName, queue.PIPE).
+//		Add(writeGreeting, httpq.ResponseWriter(), queue.PIPE))
+//
+// where parseName gets the name from the query of the request and returns
+// httpq.Status(http.StatusBadRequest, err), if it is missing (see the package example).
+package httpq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"gopkg.in/go-on/queue.v2"
+)
+
+// StatusCoder is implemented by errors that carry the HTTP status code of the response
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// StatusError is an error with an HTTP status code
+type StatusError struct {
+	// HTTP status code of the response
+	Code int
+
+	// message of the response, the message of Err for client errors or the status text if empty
+	Message string
+
+	// the underlying error, if any
+	Err error
+}
+
+// Status returns a StatusError with the given code for err.
+// For client errors (code < 500) the message of err is the message of the response.
+func Status(code int, err error) error {
+	return StatusError{Code: code, Err: err}
+}
+
+func (s StatusError) Error() string {
+	msg := s.Message
+	if msg == "" && s.Err != nil {
+		msg = s.Err.Error()
+	}
+	if msg == "" {
+		return fmt.Sprintf("%d %s", s.Code, http.StatusText(s.Code))
+	}
+	return fmt.Sprintf("%d %s: %s", s.Code, http.StatusText(s.Code), msg)
+}
+
+// StatusCode returns the HTTP status code
+func (s StatusError) StatusCode() int { return s.Code }
+
+// Unwrap returns the underlying error
+func (s StatusError) Unwrap() error { return s.Err }
+
+// response returns the message of the response: the Message, if it is set, otherwise for client
+// errors the message of the underlying error and the status text of the code for all others
+func (s StatusError) response() string {
+	switch {
+	case s.Message != "":
+		return s.Message
+	case s.Code < 500 && s.Err != nil:
+		return s.Err.Error()
+	}
+	return http.StatusText(s.Code)
+}
+
+// Handler serves HTTP requests by running a queue. The queue gets the http.ResponseWriter and
+// the *http.Request piped into its first call and is run with the context of the request,
+// so that the run stops, if the request is canceled.
+//
+// If the run fails, the error is written as response, unless the response is already written:
+//
+//   - errors that implement StatusCoder (also wrapped ones, see errors.As()) with their status code
+//     and message (see StatusError), invalid status codes (outside 200-999) are handled like other
+//     errors
+//   - queue.CallPanic and panics of the run (e.g. with the error handler PANIC) are logged and
+//     written as 500 Internal Server Error
+//   - errors of canceled requests are logged and not written, errors of exceeded deadlines
+//     are written as 504 Gateway Timeout
+//   - all other errors are logged and written as 500 Internal Server Error
+type Handler struct {
+	// Queue is run for every request
+	Queue *queue.Queue
+
+	// JSON lets errors always be written as JSON object with the fields "status" and "error".
+	// Otherwise they are only written as JSON, if the request accepts application/json, and
+	// as plain text if not.
+	JSON bool
+
+	// Logger logs server errors and panics, log.Default() if nil
+	Logger *log.Logger
+
+	// WriteError writes the error with the given status code, instead of the JSON or plain text response
+	WriteError func(w http.ResponseWriter, r *http.Request, code int, err error)
+}
+
+// NewHandler returns a Handler that runs q
+func NewHandler(q *queue.Queue) *Handler {
+	return &Handler{Queue: q}
+}
+
+type requestKey struct{}
+
+// request is the request of a run and its response writer
+type request struct {
+	w *responseWriter
+	r *http.Request
+}
+
+// ServeHTTP runs the queue for the request
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := &responseWriter{ResponseWriter: w}
+	ctx := context.WithValue(r.Context(), requestKey{}, &request{w: rw, r: r})
+
+	defer func() {
+		if p := recover(); p != nil {
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			h.fail(rw, r, fmt.Errorf("panic: %v", p))
+		}
+	}()
+
+	if _, err := h.Queue.RunWithInput(ctx, http.ResponseWriter(rw), r); err != nil {
+		h.fail(rw, r, err)
+	}
+}
+
+// fail handles the error of the run of the request r
+func (h *Handler) fail(w *responseWriter, r *http.Request, err error) {
+	code := http.StatusInternalServerError
+	msg := http.StatusText(code)
+	var sc StatusCoder
+	switch {
+	case errors.Is(err, context.Canceled):
+		h.logf("%s %s canceled: %s", r.Method, r.URL, err)
+		return
+	case errors.Is(err, context.DeadlineExceeded):
+		code = http.StatusGatewayTimeout
+		msg = http.StatusText(code)
+	case errors.As(err, &sc) && validStatus(sc.StatusCode()):
+		code = sc.StatusCode()
+		msg = http.StatusText(code)
+		if s, ok := sc.(StatusError); ok {
+			msg = s.response()
+		}
+	}
+
+	if code >= 500 {
+		h.logf("%s %s failed with %d: %s", r.Method, r.URL, code, err)
+	}
+	if w.written {
+		h.logf("%s %s: can't write error %d, the response is already written", r.Method, r.URL, code)
+		return
+	}
+
+	if h.WriteError != nil {
+		h.WriteError(w, r, code, err)
+		return
+	}
+	w.Header().Del("Content-Length")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if h.JSON || acceptsJSON(r) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(struct {
+			Status int    `json:"status"`
+			Error  string `json:"error"`
+		}{code, msg})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(code)
+	fmt.Fprintln(w, msg)
+}
+
+// validStatus reports, if code is the status code of a final response. net/http panics for
+// codes outside 100-999 and 1xx codes are informational.
+func validStatus(code int) bool {
+	return code >= 200 && code <= 999
+}
+
+func (h *Handler) logf(format string, a ...interface{}) {
+	l := h.Logger
+	if l == nil {
+		l = log.Default()
+	}
+	l.Printf(format, a...)
+}
+
+func acceptsJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(accept, ";", 2)[0])
+		if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+			return true
+		}
+	}
+	return false
+}
+
+// responseWriter remembers, if the response is written
+type responseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the response, if the underlying ResponseWriter is a http.Flusher
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.written = true
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter (see http.ResponseController)
+func (w *responseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// ErrNoRequest is returned, if the request of a run is needed outside of a Handler
+var ErrNoRequest = errors.New("httpq: the run has no request")
+
+func fromContext(ctx context.Context) (*request, error) {
+	req, ok := ctx.Value(requestKey{}).(*request)
+	if !ok {
+		return nil, ErrNoRequest
+	}
+	return req, nil
+}
+
+// ResponseWriterFromContext returns the http.ResponseWriter of the run of the given context,
+// ErrNoRequest if it is not run by a Handler
+func ResponseWriterFromContext(ctx context.Context) (http.ResponseWriter, error) {
+	req, err := fromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return req.w, nil
+}
+
+// RequestFromContext returns the *http.Request of the run of the given context,
+// ErrNoRequest if it is not run by a Handler
+func RequestFromContext(ctx context.Context) (*http.Request, error) {
+	req, err := fromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return req.r, nil
+}
+
+// ResponseWriter returns a pseudo argument that is replaced by the http.ResponseWriter of the run,
+// like queue.Call(ResponseWriterFromContext, queue.CTX)
+func ResponseWriter() interface{} {
+	return queue.Call(ResponseWriterFromContext, queue.CTX)
+}
+
+// Request returns a pseudo argument that is replaced by the *http.Request of the run,
+// like queue.Call(RequestFromContext, queue.CTX)
+func Request() interface{} {
+	return queue.Call(RequestFromContext, queue.CTX)
+}
@@ -0,0 +1,177 @@
+package httpq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/go-on/queue.v2"
+)
+
+var errNotFound = errors.New("no such user")
+
+func parseID(w http.ResponseWriter, r *http.Request) (int, error) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		return 0, Status(http.StatusBadRequest, errors.New("invalid id"))
+	}
+	return id, nil
+}
+
+func loadUser(id int) (string, error) {
+	switch id {
+	case 1:
+		return "Tim", nil
+	case 2:
+		panic("broken user")
+	case 3:
+		return "", errors.New("database down")
+	}
+	return "", StatusError{Code: http.StatusNotFound, Err: errNotFound}
+}
+
+func writeUser(w http.ResponseWriter, name string) error {
+	_, err := w.Write([]byte("hello " + name))
+	return err
+}
+
+func newTestHandler(logs *bytes.Buffer) *Handler {
+	h := NewHandler(queue.New().
+		Add(parseID, queue.PIPE).
+		Add(loadUser, queue.PIPE).
+		Add(writeUser, ResponseWriter(), queue.PIPE))
+	h.Logger = log.New(logs, "", 0)
+	return h
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		query  string
+		accept string
+		code   int
+		body   string
+		logged bool
+	}{
+		{"id=1", "", 200, "hello Tim", false},
+		{"id=x", "", 400, "invalid id\n", false},
+		{"id=4", "", 404, "no such user\n", false},
+		{"id=4", "application/json", 404, `{"status":404,"error":"no such user"}` + "\n", false},
+		{"id=3", "", 500, "Internal Server Error\n", true},
+		{"id=2", "text/html, application/json;q=0.9", 500, `{"status":500,"error":"Internal Server Error"}` + "\n", true},
+	}
+
+	for i, test := range tests {
+		var logs bytes.Buffer
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/user?"+test.query, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		newTestHandler(&logs).ServeHTTP(w, r)
+
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("tests[%d]: expected %d %q, but got %d %q", i, test.code, test.body, w.Code, w.Body.String())
+		}
+		if logged := logs.Len() > 0; logged != test.logged {
+			t.Errorf("tests[%d]: expected logged to be %v, but got %q", i, test.logged, logs.String())
+		}
+	}
+}
+
+func TestHandlerPanic(t *testing.T) {
+	var logs bytes.Buffer
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/user?id=2", nil)
+	newTestHandler(&logs).ServeHTTP(w, r)
+
+	if !strings.Contains(logs.String(), "broken user") {
+		t.Errorf("the CallPanic should be logged, but got %q", logs.String())
+	}
+
+	// a panic of the run itself
+	logs.Reset()
+	w = httptest.NewRecorder()
+	h := newTestHandler(&logs)
+	h.Queue.OnError(queue.PANIC)
+	h.ServeHTTP(w, r)
+	if w.Code != 500 || !strings.Contains(logs.String(), "broken user") {
+		t.Errorf("expecting logged 500, but got %d %q", w.Code, logs.String())
+	}
+}
+
+func TestHandlerOptions(t *testing.T) {
+	var logs bytes.Buffer
+	h := newTestHandler(&logs)
+	h.JSON = true
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/user?id=x", nil))
+	if ct := w.Header().Get("Content-Type"); w.Code != 400 || !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("expecting JSON 400, but got %d %q", w.Code, ct)
+	}
+
+	var got error
+	h.WriteError = func(w http.ResponseWriter, r *http.Request, code int, err error) {
+		got = err
+		w.WriteHeader(code)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/user?id=4", nil))
+	if w.Code != 404 || !errors.Is(got, errNotFound) || w.Body.Len() != 0 {
+		t.Errorf("expecting 404 by WriteError, but got %d %v %q", w.Code, got, w.Body.String())
+	}
+}
+
+func TestHandlerInvalidStatus(t *testing.T) {
+	for _, code := range []int{0, 100, 1000} {
+		var logs bytes.Buffer
+		h := NewHandler(queue.New().Add(func() error { return Status(code, errors.New("fail")) }))
+		h.Logger = log.New(&logs, "", 0)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != 500 || w.Body.String() != "Internal Server Error\n" || logs.Len() == 0 {
+			t.Errorf("status %d: expecting logged 500, but got %d %q", code, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestHandlerWritten(t *testing.T) {
+	var logs bytes.Buffer
+	h := NewHandler(queue.New().
+		Add(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("partial")) }, queue.PIPE).
+		Add(func() error { return errors.New("fail") }))
+	h.Logger = log.New(&logs, "", 0)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 200 || w.Body.String() != "partial" || !strings.Contains(logs.String(), "already written") {
+		t.Errorf("the written response should be kept, but got %d %q, %q", w.Code, w.Body.String(), logs.String())
+	}
+}
+
+func TestHandlerCanceled(t *testing.T) {
+	var logs bytes.Buffer
+	ctx, cancel := context.WithCancel(context.Background())
+	var ran bool
+	h := NewHandler(queue.New().
+		Add(func(w http.ResponseWriter, r *http.Request) { cancel() }, queue.PIPE).
+		Add(func() { ran = true }))
+	h.Logger = log.New(&logs, "", 0)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	if ran || w.Body.Len() != 0 || !strings.Contains(logs.String(), "canceled") {
+		t.Errorf("the run should stop with the request, but got %v, %q, %q", ran, w.Body.String(), logs.String())
+	}
+}
+
+func TestNoRequest(t *testing.T) {
+	err := queue.New().Add(func(r *http.Request) {}, Request()).Run()
+	if !errors.Is(err, ErrNoRequest) {
+		t.Errorf("expecting ErrNoRequest, but got %v", err)
+	}
+}
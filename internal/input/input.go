@@ -0,0 +1,64 @@
+// Package input decodes the input values of queues (see queue.RunWithInput()) from JSON
+// into the types of the parameters that get them.
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"gopkg.in/go-on/queue.v2"
+	"gopkg.in/go-on/queue.v2/internal/params"
+)
+
+// Types returns the types of the parameters of the first call of q that gets the n input
+// values via PIPE. The type of an input value is nil if it is unknown.
+func Types(q *queue.Queue, n int) []reflect.Type {
+	types := make([]reflect.Type, n)
+	steps := q.Steps()
+	if n == 0 || len(steps) == 0 {
+		return types
+	}
+
+	st := steps[0]
+	if st.Kind == queue.StepSub {
+		if len(st.Queues) == 0 {
+			return types
+		}
+		return Types(st.Queues[0], n)
+	}
+
+	for pos, a := range st.Args {
+		switch a.Kind {
+		case queue.ArgPipe:
+			for i := range types {
+				types[i] = params.Type(st.Type, pos+i)
+			}
+			return types
+		case queue.ArgCall, queue.ArgRun, queue.ArgFallback, queue.ArgRace:
+			// the number of values passed by nested calls and queues is not known here
+			return types
+		}
+	}
+	return types
+}
+
+// Decode decodes the JSON values into values of the given types. Values of unknown (nil)
+// types are decoded into interface{}. Errors name the values as what, e.g. "argument".
+func Decode(data []json.RawMessage, types []reflect.Type, what string) ([]interface{}, error) {
+	values := make([]interface{}, len(data))
+	for i, d := range data {
+		if types[i] == nil {
+			if err := json.Unmarshal(d, &values[i]); err != nil {
+				return nil, fmt.Errorf("can't decode %s %d: %s", what, i, err)
+			}
+			continue
+		}
+		v := reflect.New(types[i])
+		if err := json.Unmarshal(d, v.Interface()); err != nil {
+			return nil, fmt.Errorf("can't decode %s %d into %s: %s", what, i, types[i], err)
+		}
+		values[i] = v.Elem().Interface()
+	}
+	return values, nil
+}
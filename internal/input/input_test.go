@@ -0,0 +1,42 @@
+package input
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/go-on/queue.v2"
+)
+
+func TestTypes(t *testing.T) {
+	fn := func(a int, b ...string) {}
+	tests := []struct {
+		q        *queue.Queue
+		expected []reflect.Type
+	}{
+		{queue.New().Add(fn, queue.PIPE), []reflect.Type{reflect.TypeOf(0), reflect.TypeOf(""), reflect.TypeOf("")}},
+		{queue.New().Add(fn, 1, queue.PIPE), []reflect.Type{reflect.TypeOf(""), reflect.TypeOf(""), reflect.TypeOf("")}},
+		{queue.New().Sub(queue.New().Add(fn, queue.PIPE)), []reflect.Type{reflect.TypeOf(0), reflect.TypeOf(""), reflect.TypeOf("")}},
+		{queue.New().Add(fn, queue.Call(func() int { return 1 }), queue.PIPE), []reflect.Type{nil, nil, nil}},
+		{queue.New().Add(func() {}), []reflect.Type{nil, nil, nil}},
+	}
+	for i, test := range tests {
+		if got := Types(test.q, 3); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("tests[%d]: expected %v, but got %v", i, test.expected, got)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	data := []json.RawMessage{[]byte(`1`), []byte(`1`)}
+	values, err := Decode(data, []reflect.Type{reflect.TypeOf(0), nil}, "argument")
+	if err != nil || !reflect.DeepEqual(values, []interface{}{1, 1.0}) {
+		t.Errorf("expected [1 1.0], but got %#v, %v", values, err)
+	}
+
+	_, err = Decode(data, []reflect.Type{reflect.TypeOf("")}, "argument")
+	if err == nil || !strings.HasPrefix(err.Error(), "can't decode argument 0 into string: ") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
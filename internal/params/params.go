@@ -0,0 +1,18 @@
+// Package params resolves the parameters of functions, that arguments are passed to.
+package params
+
+import "reflect"
+
+// Type returns the type of the parameter at index i of a function of type ftype,
+// nil, if there is no such parameter. The parameters of a variadic function continue with
+// the element type of its last parameter, as arguments are passed to it.
+func Type(ftype reflect.Type, i int) reflect.Type {
+	num := ftype.NumIn()
+	switch {
+	case ftype.IsVariadic() && i >= num-1:
+		return ftype.In(num - 1).Elem()
+	case i < num:
+		return ftype.In(i)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package jobs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileStore is a Store that appends the jobs as lines of JSON to a local file. Every change of
+// a job appends the whole job, so that its last line is its current state. The file is read,
+// when it is opened, and must not be used by other processes at the same time, since it is not
+// locked.
+//
+// A line that is only partly written at the end of the file, e.g. because the process crashed,
+// is removed. Compact() removes the outdated lines.
+type FileStore struct {
+	mu    sync.Mutex
+	path  string
+	file  *os.File
+	jobs  map[string]*Job
+	order []string
+}
+
+// OpenFileStore opens the FileStore of the file at path, that is created if it does not exist
+func OpenFileStore(path string) (*FileStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileStore{path: path, file: f, jobs: map[string]*Job{}}
+	if err := s.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the jobs of the file and truncates an incomplete last line
+func (s *FileStore) load() error {
+	r := bufio.NewReader(s.file)
+	var offset int64
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(data)) == 0 {
+				break
+			}
+			// the last line is incomplete
+			if err := s.file.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		offset += int64(len(data))
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			return fmt.Errorf("%s:%d: invalid job: %s", s.path, line, err)
+		}
+		s.set(&job)
+	}
+	_, err := s.file.Seek(0, io.SeekEnd)
+	return err
+}
+
+// set sets the current state of the job
+func (s *FileStore) set(job *Job) {
+	if _, ok := s.jobs[job.ID]; !ok {
+		s.order = append(s.order, job.ID)
+	}
+	s.jobs[job.ID] = job
+}
+
+// write appends the job to the file and sets it as current state
+func (s *FileStore) write(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	offset, err := s.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err = s.file.Write(append(data, '\n')); err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		// a partly written line would break every later load
+		s.file.Truncate(offset)
+		return err
+	}
+	s.set(job)
+	return nil
+}
+
+// Add stores the new job
+func (s *FileStore) Add(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[job.ID]; ok {
+		return fmt.Errorf("jobs: job %s already exists", job.ID)
+	}
+	j := *job
+	return s.write(&j)
+}
+
+// Claim claims the first pending job or running job with an expired lease
+func (s *FileStore) Claim(now, lease time.Time) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range s.order {
+		cur := s.jobs[id]
+		if cur.State != Pending && (cur.State != Running || !cur.Lease.Before(now)) {
+			continue
+		}
+		j := *cur
+		j.State = Running
+		j.Attempts++
+		j.Lease = lease
+		j.Updated = now
+		if err := s.write(&j); err != nil {
+			return nil, err
+		}
+		claimed := j
+		return &claimed, nil
+	}
+	return nil, nil
+}
+
+// Update stores the state of a claimed job
+func (s *FileStore) Update(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.jobs[job.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if cur.Attempts != job.Attempts {
+		return ErrLeaseLost
+	}
+	j := *job
+	return s.write(&j)
+}
+
+// Get returns the job with the given id
+func (s *FileStore) Get(id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	j := *cur
+	return &j, nil
+}
+
+// Compact rewrites the file with only the current state of every job
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, id := range s.order {
+		if err = enc.Encode(s.jobs[id]); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	renameErr := os.Rename(tmp, s.path)
+	s.file, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if renameErr != nil {
+		return renameErr
+	}
+	return err
+}
+
+// Close closes the file
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
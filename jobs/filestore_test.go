@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.log")
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	for _, id := range []string{"a", "b"} {
+		if err := s.Add(&Job{ID: id, Queue: "q", State: Pending, Created: now}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Add(&Job{ID: "a"}); err == nil {
+		t.Errorf("adding a job twice should fail")
+	}
+
+	job, _ := s.Claim(now, now.Add(time.Hour))
+	job.State = Done
+	if err := s.Update(job); err != nil {
+		t.Fatal(err)
+	}
+	s.Claim(now, now.Add(time.Hour))
+	s.Close()
+
+	// a line that is partly written by a crashed process
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString(`{"id":"c","sta`)
+	f.Close()
+
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	expected := map[string]State{"a": Done, "b": Running}
+	for id, state := range expected {
+		if job, err := s.Get(id); err != nil || job.State != state || job.Attempts != 1 {
+			t.Errorf("job %s: expected %s, but got %#v, %v", id, state, job, err)
+		}
+	}
+	if _, err := s.Get("c"); err != ErrNotFound {
+		t.Errorf("the incomplete job should be removed, but got %v", err)
+	}
+
+	// the lease of b expires
+	job, err = s.Claim(now.Add(2*time.Hour), now.Add(3*time.Hour))
+	if err != nil || job == nil || job.ID != "b" || job.Attempts != 2 {
+		t.Errorf("expecting b to be claimed again, but got %#v, %v", job, err)
+	}
+
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if n := bytes.Count(data, []byte("\n")); n != 2 {
+		t.Errorf("expecting 2 lines after Compact, but got %d: %s", n, data)
+	}
+	job.State = Done
+	if err := s.Update(job); err != nil {
+		t.Errorf("the store should be writable after Compact, but got %v", err)
+	}
+}
+
+func TestFileStoreInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.log")
+	os.WriteFile(path, []byte("no json\n{}\n"), 0644)
+	if _, err := OpenFileStore(path); err == nil {
+		t.Errorf("expecting error for invalid line")
+	}
+}
@@ -0,0 +1,274 @@
+// Package jobs runs queues of gopkg.in/go-on/queue.v2 later, as durable jobs:
+//
+//   - Register registers the queues that may be run as jobs under a name
+//   - Enqueue stores a job that runs a registered queue with the given arguments
+//   - Work runs the jobs of the Store, that may be shared by several workers
+//   - a job is pending, running, done or failed (see State)
+//
+// Jobs are delivered at least once: a worker claims a job for the duration of the Lease and
+// if it does not finish the job in time (e.g. because its process crashed), the job is
+// claimed again by the next worker. Therefore the queues of jobs should be idempotent.
+//
+// The jobs are kept in a Store, by default a FileStore that appends them to a local file,
+// so that no outside services are needed.
+//
+// E.g.
+//
+//	store, err := jobs.OpenFileStore("jobs.log")
+//	j := jobs.New(store).Register("mail", queue.New().Add(sendMail, queue.PIPE))
+//	j.Enqueue("mail", "tim@example.com", "hello")
+//	go j.Work(ctx)
+//
+// Queues that are defined declaratively (see queue.QueueDef) are registered after they are
+// loaded with queue.Registry.Load().
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gopkg.in/go-on/queue.v2"
+	"gopkg.in/go-on/queue.v2/internal/input"
+)
+
+// State is the state of a job
+type State string
+
+const (
+	// Pending jobs wait for a worker
+	Pending State = "pending"
+	// Running jobs are claimed by a worker until their lease expires
+	Running State = "running"
+	// Done jobs ran successfully
+	Done State = "done"
+	// Failed jobs failed in their last attempt
+	Failed State = "failed"
+)
+
+// Job is a run of a registered queue
+type Job struct {
+	ID string `json:"id"`
+
+	// name of the registered queue
+	Queue string `json:"queue"`
+
+	// arguments that are piped into the queue, as JSON
+	Args []json.RawMessage `json:"args,omitempty"`
+
+	State State `json:"state"`
+
+	// number of times the job was claimed by a worker
+	Attempts int `json:"attempts"`
+
+	// error of the last attempt
+	Error string `json:"error,omitempty"`
+
+	// end of the lease of a running job
+	Lease time.Time `json:"lease"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+var (
+	// ErrNotFound is returned, if there is no job with the given id
+	ErrNotFound = errors.New("jobs: job not found")
+
+	// ErrLeaseLost is returned, if a job is updated by a worker whose lease expired and the job
+	// was claimed again
+	ErrLeaseLost = errors.New("jobs: lease of the job is lost")
+)
+
+// Store keeps the jobs. Its methods must be safe for concurrent use. Jobs passed to or returned
+// by a Store are not modified by it afterwards.
+type Store interface {
+	// Add stores the new job
+	Add(job *Job) error
+
+	// Claim claims the first job that is pending or running with a lease that expired before now,
+	// in the order the jobs are added. It sets the job running with the given lease, increments
+	// its attempts and returns it, nil if there is no such job.
+	Claim(now, lease time.Time) (*Job, error)
+
+	// Update stores the state, error and lease of a claimed job. It returns ErrLeaseLost, if the
+	// job was claimed again since (i.e. its attempts changed) and ErrNotFound for unknown jobs.
+	Update(job *Job) error
+
+	// Get returns the job with the given id, ErrNotFound if there is none
+	Get(id string) (*Job, error)
+}
+
+// Jobs enqueues jobs into a Store and runs them with the registered queues
+type Jobs struct {
+	store Store
+
+	mu     sync.RWMutex
+	queues map[string]*queue.Queue
+
+	// Lease is the time a worker may run a job, before it is claimed by another worker.
+	// The context of the run is canceled after the lease. Default: 1 minute.
+	Lease time.Duration
+
+	// PollInterval is the time a worker waits for new jobs, if there are none. Default: 1 second.
+	PollInterval time.Duration
+
+	// MaxAttempts is the number of times a job is run, if its runs fail, before it is failed.
+	// Default: 1, i.e. failed jobs are not retried.
+	MaxAttempts int
+}
+
+// New returns Jobs that keep the jobs in store
+func New(store Store) *Jobs {
+	return &Jobs{
+		store:        store,
+		queues:       map[string]*queue.Queue{},
+		Lease:        time.Minute,
+		PollInterval: time.Second,
+		MaxAttempts:  1,
+	}
+}
+
+// Register registers q under the given name, so that jobs can run it, and may be chained.
+//
+// The arguments of the jobs are piped into q. They are decoded into the types of the
+// parameters of the first call that gets them via PIPE, other arguments are decoded as plain
+// JSON values (float64, string, bool, []interface{} or map[string]interface{}).
+func (j *Jobs) Register(name string, q *queue.Queue) *Jobs {
+	j.mu.Lock()
+	j.queues[name] = q
+	j.mu.Unlock()
+	return j
+}
+
+func (j *Jobs) queue(name string) (*queue.Queue, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	q, ok := j.queues[name]
+	return q, ok
+}
+
+// Enqueue adds a pending job that runs the queue registered under name with the given
+// arguments. The arguments must be encodable as JSON. The queue needs not to be registered
+// by the enqueuing Jobs, but by the ones that work on the jobs.
+func (j *Jobs) Enqueue(name string, args ...interface{}) (*Job, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	job := &Job{ID: id, Queue: name, State: Pending, Created: now, Updated: now}
+	for i, arg := range args {
+		data, err := json.Marshal(arg)
+		if err != nil {
+			return nil, fmt.Errorf("can't encode argument %d: %s", i, err)
+		}
+		job.Args = append(job.Args, data)
+	}
+	if err := j.store.Add(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Job returns the job with the given id
+func (j *Jobs) Job(id string) (*Job, error) {
+	return j.store.Get(id)
+}
+
+// Work runs jobs until ctx is done or the Store fails and returns that error.
+// More workers can be run as goroutines that share the Jobs. Workers in other processes need
+// a Store that supports that, which FileStore does not.
+func (j *Jobs) Work(ctx context.Context) error {
+	for {
+		job, err := j.RunNext(ctx)
+		if errors.Is(err, ErrLeaseLost) {
+			// the job is run by another worker
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if job != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(j.PollInterval):
+		}
+	}
+}
+
+// RunNext claims the next job, runs it and returns it with its new state, nil if there is no job.
+// The error is the error of the Store, not of the run, which is the Error of the job, or
+// ErrLeaseLost, if the run took longer than the Lease and the job was claimed again.
+func (j *Jobs) RunNext(ctx context.Context) (*Job, error) {
+	now := time.Now().UTC()
+	job, err := j.store.Claim(now, now.Add(j.Lease))
+	if err != nil || job == nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithDeadline(ctx, job.Lease)
+	err = j.run(runCtx, job)
+	cancel()
+
+	job.Updated = time.Now().UTC()
+	job.Lease = time.Time{}
+	switch {
+	case err == nil:
+		job.State = Done
+		job.Error = ""
+	case ctx.Err() != nil:
+		// the worker stops, the job is run again by the next one
+		job.State = Pending
+		job.Error = err.Error()
+	case job.Attempts < j.MaxAttempts:
+		job.State = Pending
+		job.Error = err.Error()
+	default:
+		job.State = Failed
+		job.Error = err.Error()
+	}
+	if err := j.store.Update(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// run runs the queue of the job
+func (j *Jobs) run(ctx context.Context, job *Job) (err error) {
+	q, ok := j.queue(job.Queue)
+	if !ok {
+		return fmt.Errorf("unknown queue %#v", job.Queue)
+	}
+	args, err := input.Decode(job.Args, input.Types(q, len(job.Args)), "argument")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("panic: %v", e)
+		}
+	}()
+	_, err = q.RunWithInput(ctx, args...)
+	return err
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
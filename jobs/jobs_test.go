@@ -0,0 +1,196 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/go-on/queue.v2"
+)
+
+type mail struct {
+	To      string
+	Subject string
+}
+
+func openTestStore(t *testing.T) *FileStore {
+	s, err := OpenFileStore(filepath.Join(t.TempDir(), "jobs.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestRunNext(t *testing.T) {
+	var sent []mail
+	send := func(m mail, n int) error {
+		if m.To == "" {
+			return errors.New("no recipient")
+		}
+		for i := 0; i < n; i++ {
+			sent = append(sent, m)
+		}
+		return nil
+	}
+	j := New(openTestStore(t)).Register("mail", queue.New().Add(send, queue.PIPE))
+
+	ok, _ := j.Enqueue("mail", mail{To: "tim", Subject: "hi"}, 2)
+	bad, _ := j.Enqueue("mail", mail{}, 1)
+	unknown, _ := j.Enqueue("sms", "tim")
+
+	expected := []struct {
+		id    string
+		state State
+		err   string
+	}{
+		{ok.ID, Done, ""},
+		{bad.ID, Failed, "no recipient"},
+		{unknown.ID, Failed, `unknown queue "sms"`},
+	}
+	for i, e := range expected {
+		job, err := j.RunNext(context.Background())
+		if err != nil || job == nil || job.ID != e.id || job.State != e.state || job.Error != e.err || job.Attempts != 1 {
+			t.Errorf("jobs[%d]: expected %s %s %q, but got %#v, %v", i, e.id, e.state, e.err, job, err)
+		}
+		if stored, _ := j.Job(e.id); stored == nil || stored.State != e.state {
+			t.Errorf("jobs[%d]: expected stored state %s, but got %#v", i, e.state, stored)
+		}
+	}
+
+	if job, err := j.RunNext(context.Background()); job != nil || err != nil {
+		t.Errorf("expecting no job, but got %#v, %v", job, err)
+	}
+	if !reflect.DeepEqual(sent, []mail{{"tim", "hi"}, {"tim", "hi"}}) {
+		t.Errorf("expecting 2 mails, but got %#v", sent)
+	}
+	if _, err := j.Job("missing"); err != ErrNotFound {
+		t.Errorf("expecting ErrNotFound, but got %v", err)
+	}
+	if _, err := j.Enqueue("mail", func() {}); err == nil {
+		t.Errorf("expecting error for argument that can't be encoded")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	calls := 0
+	flaky := func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("flaky")
+		}
+		return nil
+	}
+	j := New(openTestStore(t)).Register("flaky", queue.New().Add(flaky))
+	j.MaxAttempts = 3
+	enqueued, _ := j.Enqueue("flaky")
+
+	var states []State
+	for i := 0; i < 3; i++ {
+		job, err := j.RunNext(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		states = append(states, job.State)
+	}
+	job, _ := j.Job(enqueued.ID)
+	if !reflect.DeepEqual(states, []State{Pending, Pending, Done}) || job.Attempts != 3 || job.Error != "" {
+		t.Errorf("expecting done after 3 attempts, but got %v, %#v", states, job)
+	}
+}
+
+func TestLease(t *testing.T) {
+	store := openTestStore(t)
+	j := New(store).Register("noop", queue.New().Add(func() {}))
+	enqueued, _ := j.Enqueue("noop")
+
+	// a worker that crashed while running the job
+	now := time.Now()
+	crashed, err := store.Claim(now, now.Add(time.Millisecond))
+	if err != nil || crashed == nil || crashed.ID != enqueued.ID {
+		t.Fatalf("expecting claimed job, but got %#v, %v", crashed, err)
+	}
+	if job, _ := j.RunNext(context.Background()); job != nil {
+		t.Errorf("the job should be leased, but got %#v", job)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	job, err := j.RunNext(context.Background())
+	if err != nil || job == nil || job.State != Done || job.Attempts != 2 {
+		t.Errorf("the job should be delivered again, but got %#v, %v", job, err)
+	}
+
+	crashed.State = Done
+	if err := store.Update(crashed); err != ErrLeaseLost {
+		t.Errorf("expecting ErrLeaseLost, but got %v", err)
+	}
+}
+
+func TestLeaseTimeout(t *testing.T) {
+	wait := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	j := New(openTestStore(t)).Register("wait", queue.New().Add(wait, queue.CTX))
+	j.Lease = 10 * time.Millisecond
+	j.Enqueue("wait")
+
+	job, err := j.RunNext(context.Background())
+	if err != nil || job.State != Failed || !strings.Contains(job.Error, "deadline") {
+		t.Errorf("the run should be canceled after the lease, but got %#v, %v", job, err)
+	}
+}
+
+func TestWork(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	record := func(s string) {
+		mu.Lock()
+		got = append(got, s)
+		mu.Unlock()
+	}
+	j := New(openTestStore(t)).Register("record", queue.New().Add(record, queue.PIPE))
+	j.PollInterval = time.Millisecond
+	for _, s := range []string{"a", "b", "c"} {
+		j.Enqueue("record", s)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- j.Work(ctx)
+		}()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n == 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != context.Canceled {
+			t.Errorf("expecting context.Canceled, but got %v", err)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("every job should run once, but got %v", got)
+	}
+}
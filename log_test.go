@@ -44,7 +44,7 @@ logtest - DEBUG: [1] func() string{}() => "7"
 logtest - DEBUG: [2] func(string) (int, error){}("7") => 7, <nil>
 logtest - DEBUG: [3] func(int) error{}(7) => <nil>
 logtest - PANIC: [4] Panic in func(string) error: reflect: Call with too few input arguments
-logtest - DEBUG: [E] queue.ErrHandlerFunc(queue.CallPanic{Position:4, Type:"func(string) error", Params:[]interface {}{}, ErrorMessage:"reflect: Call with too few input arguments", Name:""}) => queue.CallPanic{Position:4, Type:"func(string) error", Params:[]interface {}{}, ErrorMessage:"reflect: Call with too few input arguments", Name:""}`,
+logtest - DEBUG: [E] queue.ErrHandlerFunc(queue.CallPanic{Position:4, Type:"func(string) error", Params:[]interface {}{}, ErrorMessage:"reflect: Call with too few input arguments", Name:"", Path:""}) => queue.CallPanic{Position:4, Type:"func(string) error", Params:[]interface {}{}, ErrorMessage:"reflect: Call with too few input arguments", Name:"", Path:""}`,
 			`
 PANIC: [4] Panic in func(string) error: reflect: Call with too few input arguments`,
 			newF(set, "7"),
@@ -3,22 +3,39 @@ package queue
 // calls the func at position i, with its arguments,
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+
+	"gopkg.in/go-on/queue.v2/internal/params"
 )
 
 // an internal type used to identify the pseudo parameter PIPE
 type pipe struct{}
 
 // PIPE is a pseudo parameter that will be replaced by the returned
-// non error values of the previous function
+// non error values of the previous function.
+//
+// A nil (piped or passed as literal argument) becomes the zero value of the parameter
+// that gets it, e.g. "" for a string, in Check() and Run() alike. Typed nils are passed as they are.
 var PIPE = pipe{}
 
+// an internal type used to identify the pseudo parameter CTX
+type ctxArg struct{}
+
+// CTX is a pseudo parameter that will be replaced by the context.Context
+// of the run (see RunContext())
+var CTX = ctxArg{}
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+var emptyInterfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
 func isNilable(obj interface {
 	Kind() reflect.Kind
 }) bool {
 	switch obj.Kind() {
-	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice, reflect.UnsafePointer:
 		return true
 	default:
 		return false
@@ -31,25 +48,39 @@ func isNilable(obj interface {
 // last returned value is an error, it is stripped out and returned
 // separately
 // it catches any call panic
-func (q *Queue) pipeFn(c *call, i int, piped []reflect.Value) (returns []reflect.Value, err error) {
+func (q *Queue) pipeFn(ctx context.Context, c *call, i int, piped []reflect.Value) (returns []reflect.Value, err error) {
 	all := []interface{}{}
+	// values passed via Call(), Run(), Fallback() or Race(), that might be closed automatically
+	var passed []reflect.Value
 
 	for j, p := range c.arguments {
 		switch a := p.(type) {
 		case pipe:
 			all = append(all, toInterfaces(piped)...)
+		case ctxArg:
+			all = append(all, ctx)
 		case *call:
-			returns, err = q.pipeFn(a, i*100+j*10, piped)
+			returns, err = q.pipeFn(stepAt(ctx, "arg", j), a, i*100+j*10, piped)
+			if ab := debugAborted(ctx); ab != nil {
+				return nil, ab
+			}
+			if _, halted := isHalt(err, nil); err != nil && !halted {
+				// unhandled errors are passed to the error handler of the queue by the caller
+				err, _ = q.handleStepError(a, err, "E")
+			}
 			if err != nil {
 				return
 			}
 			all = append(all, toInterfaces(returns)...)
+			passed = append(passed, returns...)
 		case callrun:
-			errHandler := q.defaultErrHandler()
-			// default error handler is STOP
+			errHandler := q.runErrHandler(ctx)
 			vals := piped
-			for _, qe := range a {
-				vals, err = qe.Queue().runAndReturn(vals)
+			for k, qe := range a {
+				vals, err = qe.Queue().runAndReturn(stepAt(stepAt(ctx, "arg", j), "run", k), vals)
+				if ab := debugAborted(ctx); ab != nil {
+					return nil, ab
+				}
 				if err != nil {
 					err2 := errHandler.HandleError(err)
 					q.logDebug("[E] %T(%#v) => %#v", errHandler, err, err2)
@@ -61,10 +92,14 @@ func (q *Queue) pipeFn(c *call, i int, piped []reflect.Value) (returns []reflect
 			}
 
 			all = append(all, toInterfaces(vals)...)
+			passed = append(passed, vals...)
 		case callfallback:
-			errHandler := q.defaultErrHandler()
-			for _, qe := range a {
-				returns, err = qe.Queue().runAndReturn(piped)
+			errHandler := q.runErrHandler(ctx)
+			for k, qe := range a {
+				returns, err = qe.Queue().runAndReturn(stepAt(stepAt(ctx, "arg", j), "fallback", k), piped)
+				if ab := debugAborted(ctx); ab != nil {
+					return nil, ab
+				}
 				if err == nil {
 					break
 				}
@@ -80,6 +115,25 @@ func (q *Queue) pipeFn(c *call, i int, piped []reflect.Value) (returns []reflect
 			}
 
 			all = append(all, toInterfaces(returns)...)
+			passed = append(passed, returns...)
+		case callrace:
+			errHandler := q.runErrHandler(ctx)
+			returns, err = q.race(stepAt(ctx, "arg", j), a, piped)
+			if ab := debugAborted(ctx); ab != nil {
+				return nil, ab
+			}
+
+			if err != nil {
+				err2 := errHandler.HandleError(err)
+				q.logDebug("[E] %T(%#v) => %#v", errHandler, err, err2)
+				err = err2
+			}
+			if err != nil {
+				return
+			}
+
+			all = append(all, toInterfaces(returns)...)
+			passed = append(passed, returns...)
 		default:
 			all = append(all, p)
 		}
@@ -94,7 +148,9 @@ func (q *Queue) pipeFn(c *call, i int, piped []reflect.Value) (returns []reflect
 			ce.Type = c.function.Type().String()
 			ce.Position = i
 			ce.Name = c.name
+			ce.Path = stepPath(ctx)
 			err = ce
+			notePanic(ctx, ce)
 			if c.name == "" {
 				q.logPanic("[%d] Panic in %v: %v", i, c.function.Type().String(), e)
 			} else {
@@ -104,16 +160,67 @@ func (q *Queue) pipeFn(c *call, i int, piped []reflect.Value) (returns []reflect
 		}
 	}()
 
+	// a panic of a breakpoint handler is handled like a panic of the call
+	action, all, err := q.debugStep(ctx, c, all, piped)
+	switch action {
+	case DebugSkip:
+		return piped, nil
+	case DebugFail, DebugAbort:
+		return nil, err
+	}
+
 	vals := toValues(all)
 	for ia := range vals {
-		if isNilable(vals[ia]) && vals[ia].IsNil() {
-			ty := c.function.Type().In(ia)
-			vals[ia] = reflect.New(ty).Elem()
+		// untyped nils (see toValues()) from literals, PIPE etc. become the zero value of their
+		// parameter (nil for types that can be nil), typed nils are passed as they are
+		if vals[ia].Kind() != reflect.Interface || !vals[ia].IsNil() {
+			continue
+		}
+		ty := params.Type(c.function.Type(), ia)
+		if ty == nil {
+			// there is no such parameter, which reflect reports
+			continue
+		}
+		vals[ia] = reflect.New(ty).Elem()
+	}
+	if conv := runConverter(ctx); conv != nil && c.feed == 0 {
+		var invalid error
+		invalid, err = conv.convertArgs(c.function.Type(), vals)
+		if invalid != nil {
+			err = InvalidArgument{
+				Position:     i,
+				Type:         c.function.Type().String(),
+				ErrorMessage: invalid.Error(),
+				Name:         c.name,
+			}
+		}
+		if err != nil {
+			return
 		}
 	}
-	returns = c.function.Call(vals)
+	fn := c.function
+	if c.feed != 0 {
+		fn = reflect.ValueOf(func(args ...interface{}) error {
+			return q.runFeeded(ctx, c, args)
+		})
+	}
+	var replayErr error
+	fn = replayStub(ctx, c, q.failureOf(c), all, fn, &replayErr)
+	returns = fn.Call(vals)
+	recordStep(ctx, c, q.failureOf(c), all, returns)
+	if ac := runAutoCloser(ctx); ac != nil && len(passed) > 0 {
+		// passed values that are not returned are closed, the error of the call comes first
+		ac.track(passed, piped)
+		defer func(returned []reflect.Value) {
+			closeErrs := ac.release(passed, concat(returned, piped))
+			if err == nil && len(closeErrs) > 0 {
+				err = closeErrs[0]
+			}
+		}(returns)
+	}
 	num := c.function.Type().NumOut()
 	if num == 0 {
+		err = replayErr
 		return
 	}
 
@@ -135,22 +242,27 @@ func (q *Queue) pipeFn(c *call, i int, piped []reflect.Value) (returns []reflect
 	}
 
 	last := num - 1
-	// TODO: there should be a better way to do this
-	if c.function.Type().Out(last).String() == "error" {
-		res := returns[last]
+	if reportsFailure(c.function.Type().Out(last), q.failureOf(c)) {
+		err = failed(returns[last])
 		returns = returns[:last]
-		if !res.IsNil() {
-			err = res.Interface().(error)
-			if !q.logverbose {
-				if c.name == "" {
-					q.logError("[%d] %v => error: %#v",
-						i, c.function.Type().String(), err,
-					)
-				} else {
-					q.logError("[%d] %#v %v => error: %#v",
-						i, c.name, c.function.Type().String(), err,
-					)
-				}
+	}
+	if replayErr != nil {
+		// the replayed error can't be returned by the function (see replayStub())
+		err = replayErr
+	}
+	if _, notOK := err.(NotOK); notOK {
+		err = NotOK{Position: i, Type: c.function.Type().String(), Name: c.name, Path: stepPath(ctx)}
+	}
+	if err != nil {
+		if _, halted := isHalt(err, nil); !halted && !q.logverbose {
+			if c.name == "" {
+				q.logError("[%d] %v => error: %#v",
+					i, c.function.Type().String(), err,
+				)
+			} else {
+				q.logError("[%d] %#v %v => error: %#v",
+					i, c.name, c.function.Type().String(), err,
+				)
 			}
 		}
 	}
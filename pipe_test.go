@@ -1,9 +1,11 @@
 package queue
 
 import (
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"unsafe"
 )
 
 var testsPipe = []testcase{
@@ -59,3 +61,155 @@ func TestPipeMethod(t *testing.T) {
 		t.Errorf("expecting no error, but got: %s", err.Error())
 	}
 }
+
+func TestPipeNil(t *testing.T) {
+	var got []interface{}
+	ptrs := func(ps ...*int) { got = append(got, len(ps)) }
+	iface := func(i interface{}) { got = append(got, i == nil) }
+	str := func(s ...string) { got = append(got, s) }
+
+	tests := []*Queue{
+		Add(ptrs, nil),
+		Add(ptrs, (*int)(nil)),
+		Add(ptrs, (*int)(nil), nil, new(int)),
+		Add(iface, nil),
+		Add(iface, (*int)(nil)),
+		Add(str, nil),
+		Add(str, "a", nil),
+	}
+	expected := []interface{}{1, 1, 3, true, false, []string{""}, []string{"a", ""}}
+
+	for i, q := range tests {
+		got = nil
+		if err := q.Check(); err != nil {
+			t.Errorf("tests[%d]: Check should accept, but got %s", i, err)
+		}
+		if err := q.Run(); err != nil {
+			t.Errorf("tests[%d]: Run should work, but got %s", i, err)
+		}
+		if len(got) != 1 || !reflect.DeepEqual(got[0], expected[i]) {
+			t.Errorf("tests[%d]: expected %#v, but got %#v", i, expected[i], got)
+		}
+	}
+
+	// nil for a parameter that does not exist
+	q := Add(func() {}, nil)
+	if _, ok := q.Check().(InvalidArgument); !ok {
+		t.Errorf("expecting InvalidArgument, but got %v", q.Check())
+	}
+}
+
+// nilKinds returns a function with a parameter of type t and a variadic one, that both
+// record, if their arguments are the zero value (nil for types that can be nil)
+func nilKinds(t reflect.Type, got *[]bool) (fn, variadic interface{}) {
+	record := func(args []reflect.Value) []reflect.Value {
+		for _, a := range args {
+			if a.Kind() == reflect.Slice && a.Type().Elem() == t {
+				for i := 0; i < a.Len(); i++ {
+					*got = append(*got, a.Index(i).IsZero())
+				}
+				continue
+			}
+			*got = append(*got, a.IsZero())
+		}
+		return nil
+	}
+	fn = reflect.MakeFunc(reflect.FuncOf([]reflect.Type{t}, nil, false), record).Interface()
+	variadic = reflect.MakeFunc(reflect.FuncOf([]reflect.Type{reflect.SliceOf(t)}, nil, true), record).Interface()
+	return
+}
+
+func TestNilKinds(t *testing.T) {
+	nilable := []interface{}{
+		(chan int)(nil),
+		(func())(nil),
+		(*interface{})(nil),
+		(*error)(nil),
+		(map[string]int)(nil),
+		(*int)(nil),
+		([]int)(nil),
+		unsafe.Pointer(nil),
+	}
+
+	for _, v := range nilable {
+		ty := reflect.TypeOf(v)
+		if ty.Kind() == reflect.Ptr && ty.Elem().Kind() == reflect.Interface {
+			ty = ty.Elem()
+		}
+		typedNil := reflect.Zero(ty).Interface()
+		source := reflect.MakeFunc(reflect.FuncOf(nil, []reflect.Type{ty}, false), func([]reflect.Value) []reflect.Value {
+			return []reflect.Value{reflect.Zero(ty)}
+		}).Interface()
+
+		var got []bool
+		fn, variadic := nilKinds(ty, &got)
+
+		tests := map[string]*Queue{
+			"nil literal":   Add(fn, nil),
+			"typed nil":     Add(fn, typedNil),
+			"variadic nils": Add(variadic, nil, typedNil, nil),
+		}
+		// returned errors are handled, not piped
+		if ty != errorType {
+			tests["piped nil"] = Add(source).Add(fn, PIPE)
+		}
+
+		for name, q := range tests {
+			got = nil
+			if err := q.Check(); err != nil {
+				t.Errorf("%s %s: Check should accept, but got %s", ty, name, err)
+			}
+			if err := q.Run(); err != nil {
+				t.Errorf("%s %s: Run should work, but got %s", ty, name, err)
+			}
+			if len(got) == 0 {
+				t.Errorf("%s %s: function is not called", ty, name)
+			}
+			for i, isNil := range got {
+				if !isNil {
+					t.Errorf("%s %s: %d. argument should be nil", ty, name, i+1)
+				}
+			}
+		}
+	}
+
+	// untyped nils become the zero value of parameters that can't be nil
+	notNilable := []interface{}{0, "", false, struct{}{}, [2]int{}, 1.5}
+
+	for _, v := range notNilable {
+		ty := reflect.TypeOf(v)
+		var got []bool
+		fn, variadic := nilKinds(ty, &got)
+		zero := reflect.Zero(ty).Interface()
+
+		tests := map[string]*Queue{
+			"nil literal":   Add(fn, nil),
+			"variadic nils": Add(variadic, zero, nil, nil),
+		}
+
+		for name, q := range tests {
+			got = nil
+			if err := q.Check(); err != nil {
+				t.Errorf("%s %s: Check should accept, but got %s", ty, name, err)
+			}
+			if err := q.Run(); err != nil {
+				t.Errorf("%s %s: Run should work, but got %s", ty, name, err)
+			}
+			if len(got) == 0 {
+				t.Errorf("%s %s: function is not called", ty, name)
+			}
+			for i, isZero := range got {
+				if !isZero {
+					t.Errorf("%s %s: %d. argument should be the zero value", ty, name, i+1)
+				}
+			}
+		}
+
+		// a nil piped as interface{} is only known in the run
+		got = nil
+		source := func() interface{} { return nil }
+		if err := Add(source).Add(fn, PIPE).Run(); err != nil || !reflect.DeepEqual(got, []bool{true}) {
+			t.Errorf("%s piped nil: Run should pass the zero value, but got %v and %v", ty, got, err)
+		}
+	}
+}
@@ -36,19 +36,28 @@ import (
 )
 
 var (
-	V         = queue.PIPE
-	STOP      = queue.STOP
-	IGNORE    = queue.IGNORE
-	PANIC     = queue.PANIC
-	Call      = queue.Call
-	CallNamed = queue.CallNamed
-	Get       = queue.Get
-	Set       = queue.Set
-	Collect   = queue.Collect
-	Value     = queue.Value
-	Ok        = queue.Ok
-	Fallback  = queue.Fallback
-	Run       = queue.Run
+	V           = queue.PIPE
+	STOP        = queue.STOP
+	IGNORE      = queue.IGNORE
+	PANIC       = queue.PANIC
+	Call        = queue.Call
+	CallNamed   = queue.CallNamed
+	Get         = queue.Get
+	Set         = queue.Set
+	Collect     = queue.Collect
+	Value       = queue.Value
+	Ok          = queue.Ok
+	Fallback    = queue.Fallback
+	Run         = queue.Run
+	Race        = queue.Race
+	Hedge       = queue.Hedge
+	CTX         = queue.CTX
+	Halt        = queue.Halt
+	Return      = queue.Return
+	FallThrough = queue.FallThrough
+	Isolate     = queue.Isolate
+	Inherit     = queue.Inherit
+	Override    = queue.Override
 )
 
 type (
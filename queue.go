@@ -11,6 +11,12 @@ type Queue struct {
 
 	errHandler ErrHandler
 
+	// error handlers of the calls with the given names (see OnErrorAt())
+	errHandlersAt map[string]ErrHandler
+
+	// which error handler is used when nested in another queue
+	inheritance Inheritance
+
 	logTarget io.Writer
 
 	logverbose bool
@@ -24,6 +30,30 @@ type Queue struct {
 
 	// optional name of the queue (for logging and debugging)
 	name string
+
+	// max nesting depth of queues, when the queue is run (see SetMaxDepth())
+	maxDepth int
+
+	// debugger of the runs of the queue (see SetDebugger())
+	debugger *Debugger
+
+	// recorder and replayer of the runs of the queue (see SetRecorder() and SetReplayer())
+	recorder *Recorder
+	replayer *Replayer
+
+	// converter of the arguments of the calls (see SetConverter())
+	converter *Converter
+
+	// failure convention of the calls (see FailOn()) and of the calls with the given names (see FailOnAt())
+	failure    Failure
+	failuresAt map[string]Failure
+
+	// close values automatically in the runs of the queue (see AutoClose())
+	autoClose bool
+	closers   []reflect.Value
+
+	// wraps the runs of the queue (see Around())
+	around AroundFunc
 }
 
 // New creates a new function queue
@@ -0,0 +1,18 @@
+// Command queuecheck checks the function queues of gopkg.in/go-on/queue.v2 at compile time.
+//
+// It may be run standalone
+//
+//	queuecheck ./...
+//
+// or with go vet
+//
+//	go vet -vettool=$(which queuecheck) ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+
+	"gopkg.in/go-on/queue.v2/queuecheck"
+)
+
+func main() { singlechecker.Main(queuecheck.Analyzer) }
@@ -0,0 +1,531 @@
+// Copyright (c) 2014 Marc René Arns. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+/*
+Package queuecheck provides an Analyzer that checks the function queues of
+gopkg.in/go-on/queue.v2 at compile time.
+
+It recognizes chains like
+
+	queue.New().Add(get, "Age", m).Add(strconv.Atoi, queue.PIPE).Add(p.SetAge, queue.PIPE)
+
+and the QFunc chains of the q package
+
+	q.Q(get, "Age", m)(strconv.Atoi, q.V)(p.SetAge, q.V)
+
+and reports the arguments that do not match the signature of the function with the
+same rules as Check(): PIPE is replaced by the values returned by the previous call
+(without a final error), variadic functions get any number of values of their element
+type and calls passed via Call() get the values returned by their function.
+
+Types that are only known at run time are not checked, e.g. the values returned by
+Sub(), Run(), Fallback() or Race(), arguments of interface types and arguments passed
+with "...". A chain that is not run directly (via Run(), CheckAndRun() etc.) might be
+nested inside another queue, so the values piped into its first call are unknown as well.
+The arguments of a chain with SetConverter() are not checked, since they might be converted.
+FailOn(queue.FailOnFalse) strips a final bool from the piped values of all calls of the chain,
+also if it comes after them, and Call().FailOn() of the nested call. Chains with FailOnAt() or
+a failure convention that is only known at run time are not checked.
+
+The Analyzer can be run with go vet via the command queuecheck/cmd/queuecheck:
+
+	go vet -vettool=$(which queuecheck) ./...
+*/
+package queuecheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// Analyzer checks the arguments of the calls in function queues
+var Analyzer = &analysis.Analyzer{
+	Name:     "queuecheck",
+	Doc:      "check that the arguments of function queues (gopkg.in/go-on/queue.v2) match the signatures of the functions",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+const (
+	queuePath = "gopkg.in/go-on/queue.v2"
+	qPath     = queuePath + "/q"
+)
+
+// methods that run or check the queue, the first call of their queue gets no piped values
+var runners = map[string]bool{
+	"Run":         true,
+	"RunContext":  true,
+	"CheckAndRun": true,
+	"Check":       true,
+	"CheckAll":    true,
+	"CheckStrict": true,
+}
+
+// methods that return the queue without changing the piped values
+var passing = map[string]bool{
+	"SetName":                true,
+	"OnError":                true,
+	"OnErrorAt":              true,
+	"LogDebugTo":             true,
+	"LogErrorsTo":            true,
+	"SetInheritance":         true,
+	"SetMaxDepth":            true,
+	"SetConverter":           true,
+	"FailOn":                 true,
+	"FailOnAt":               true,
+	"AutoClose":              true,
+	"Around":                 true,
+	"SetDebugger":            true,
+	"SetRecorder":            true,
+	"SetReplayer":            true,
+	"Clone":                  true,
+	"Queue":                  true,
+	"TeeAndRun":              true,
+	"TeeAndFallback":         true,
+	"TeeAndCheckAndRun":      true,
+	"TeeAndCheckAndFallback": true,
+}
+
+var errorType = types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+
+// piped are the types of the values piped into a call
+type piped struct {
+	types []types.Type
+
+	// the types can't be inferred statically
+	unknown bool
+}
+
+var unknown = piped{unknown: true}
+
+type checker struct {
+	pass *analysis.Pass
+
+	// calls that are already checked as part of a chain
+	seen map[*ast.CallExpr]bool
+
+	// the arguments of the chain that is checked are only known at run time
+	unchecked bool
+
+	// the call that is checked has the failure convention FailOnFalse (see returns())
+	failOnFalse bool
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	// the queue packages use their internals
+	if path := strings.TrimSuffix(pass.Pkg.Path(), "_test"); path == queuePath || path == qPath {
+		return nil, nil
+	}
+
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	c := &checker{pass: pass, seen: map[*ast.CallExpr]bool{}}
+
+	// the outer calls of a chain are visited first
+	insp.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		if c.seen[call] {
+			return
+		}
+
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && runners[sel.Sel.Name] && c.isChain(sel.X) {
+			c.checkChain(sel.X, piped{})
+			return
+		}
+
+		if c.isChain(call) {
+			c.checkChain(call, unknown)
+		}
+	})
+	return nil, nil
+}
+
+// isNamed reports, if t is the named type (or a pointer to the named type) name of the package path
+func isNamed(t types.Type, path, name string) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	n, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := n.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == path && obj.Name() == name
+}
+
+// isChain reports, if e is a *queue.Queue or a q.QFunc
+func (c *checker) isChain(e ast.Expr) bool {
+	t := c.pass.TypesInfo.TypeOf(e)
+	return t != nil && (isNamed(t, queuePath, "Queue") || isNamed(t, qPath, "QFunc"))
+}
+
+// object returns the package level object of the queue or q package that is referenced by e
+// and the path of its package
+func (c *checker) object(e ast.Expr) (path, name string) {
+	var id *ast.Ident
+	switch x := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		id = x
+	case *ast.SelectorExpr:
+		id = x.Sel
+	default:
+		return
+	}
+	obj := c.pass.TypesInfo.Uses[id]
+	if obj == nil || obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+		return
+	}
+	return obj.Pkg().Path(), obj.Name()
+}
+
+// is reports, if e references the package level object of the queue package with the given name
+// or its shortcut in the q package
+func (c *checker) is(e ast.Expr, name, shortcut string) bool {
+	path, n := c.object(e)
+	return (path == queuePath && n == name) || (path == qPath && n == shortcut)
+}
+
+// checkChain checks the calls of the whole chain e that gets the input values, with the settings
+// of the chain that apply to all of its calls, wherever they are in the chain
+func (c *checker) checkChain(e ast.Expr, input piped) {
+	c.unchecked = false
+	c.failOnFalse = false
+	// FailOn() is scanned from the outermost call, so the last one counts
+	failOnSet := false
+	for x := e; ; {
+		call, ok := ast.Unparen(x).(*ast.CallExpr)
+		if !ok {
+			break
+		}
+		if c.isChain(call.Fun) {
+			x = call.Fun
+			continue
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !c.isChain(sel.X) {
+			break
+		}
+		switch sel.Sel.Name {
+		case "SetConverter", "FailOnAt":
+			c.unchecked = true
+		case "FailOn":
+			if !failOnSet && len(call.Args) == 1 {
+				failOnSet = true
+				var known bool
+				c.failOnFalse, known = c.failure(call.Args[0])
+				c.unchecked = c.unchecked || !known
+			}
+		}
+		x = sel.X
+	}
+	c.chain(e, input)
+}
+
+// failure reports, if e is queue.FailOnFalse and if the failure convention is known
+func (c *checker) failure(e ast.Expr) (failOnFalse, known bool) {
+	switch path, name := c.object(e); {
+	case path == queuePath && name == "FailOnFalse":
+		return true, true
+	case path == queuePath && name == "FailOnError":
+		return false, true
+	}
+	return false, false
+}
+
+// nestedCall returns the call of Call() or CallNamed() with the methods of the nested call
+// (e.g. OnError()) removed, and the failure convention of the nested call
+func (c *checker) nestedCall(call *ast.CallExpr) (nested *ast.CallExpr, failOnFalse, known bool) {
+	failOnFalse, known = c.failOnFalse, true
+	failOnSet := false
+	for {
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !isNamed(c.pass.TypesInfo.TypeOf(sel.X), queuePath, "call") {
+			return call, failOnFalse, known
+		}
+		if sel.Sel.Name == "FailOn" && !failOnSet && len(call.Args) == 1 {
+			failOnSet = true
+			failOnFalse, known = c.failure(call.Args[0])
+		}
+		inner, ok := ast.Unparen(sel.X).(*ast.CallExpr)
+		if !ok {
+			return call, failOnFalse, false
+		}
+		call = inner
+	}
+}
+
+// chain checks the calls of the chain e that gets the input values and returns the types of the
+// values returned by its last call
+func (c *checker) chain(e ast.Expr, input piped) piped {
+	call, ok := ast.Unparen(e).(*ast.CallExpr)
+	if !ok {
+		// the queue is a variable etc.
+		return unknown
+	}
+
+	if path, name := c.object(call.Fun); path == queuePath || path == qPath {
+		c.seen[call] = true
+		switch {
+		case path == queuePath && (name == "New" || name == "OnError"), path == qPath && name == "Err":
+			return input
+		case path == queuePath && name == "Add", path == qPath && name == "Q":
+			return c.step(call, call.Args, input)
+		case path == queuePath && name == "AddNamed":
+			return c.step(call, tail(call.Args), input)
+		}
+		return unknown
+	}
+
+	// a call of a QFunc adds a call to the queue
+	if c.isChain(call.Fun) {
+		c.seen[call] = true
+		return c.step(call, call.Args, c.chain(call.Fun, input))
+	}
+
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || !c.isChain(sel.X) {
+		return unknown
+	}
+	c.seen[call] = true
+	prev := c.chain(sel.X, input)
+
+	switch name := sel.Sel.Name; {
+	case name == "Add":
+		return c.step(call, call.Args, prev)
+	case name == "AddNamed", name == "AddWithHandler":
+		return c.step(call, tail(call.Args), prev)
+	case name == "Tee":
+		c.step(call, call.Args, prev)
+		return prev
+	case name == "TeeNamed", name == "TeeWithHandler":
+		c.step(call, tail(call.Args), prev)
+		return prev
+	case passing[name]:
+		return prev
+	}
+	return unknown
+}
+
+func tail(args []ast.Expr) []ast.Expr {
+	if len(args) == 0 {
+		return nil
+	}
+	return args[1:]
+}
+
+// step checks the call of the function args[0] with the arguments args[1:] and the given piped values.
+// It returns the types of the values returned by the function without a final error.
+func (c *checker) step(call *ast.CallExpr, args []ast.Expr, in piped) piped {
+	if len(args) == 0 || call.Ellipsis.IsValid() {
+		return unknown
+	}
+
+	fn := args[0]
+	t := c.pass.TypesInfo.TypeOf(fn)
+	if t == nil || types.IsInterface(t) {
+		return unknown
+	}
+
+	sig, ok := t.Underlying().(*types.Signature)
+	if !ok {
+		if b, isBasic := t.(*types.Basic); isBasic && b.Kind() == types.UntypedNil {
+			return unknown
+		}
+		c.pass.Reportf(fn.Pos(), "function %#v is invalid: %#v is no func", typeString(t), typeString(t))
+		return unknown
+	}
+
+	all, exprs, ok := c.arguments(args[1:], in)
+	if ok && !c.unchecked {
+		if pos, msg := validateArgs(sig, all); msg != "" {
+			at := fn
+			if pos >= 0 {
+				at = exprs[pos]
+			}
+			c.pass.Reportf(at.Pos(), "function %#v gets invalid argument: %s", typeString(sig), msg)
+		}
+	}
+	return returns(sig, c.failOnFalse)
+}
+
+// arguments returns the types of the arguments passed to a function and the expressions they
+// come from. ok is false, if the number of arguments is unknown. Unknown types are nil.
+func (c *checker) arguments(args []ast.Expr, in piped) (all []types.Type, exprs []ast.Expr, ok bool) {
+	for _, a := range args {
+		switch {
+		case c.is(a, "PIPE", "V"):
+			if in.unknown {
+				return nil, nil, false
+			}
+			for _, t := range in.types {
+				all = append(all, t)
+				exprs = append(exprs, a)
+			}
+		case c.is(a, "CTX", "CTX"):
+			// a context.Context, that is passed to any context parameter
+			all = append(all, nil)
+			exprs = append(exprs, a)
+		default:
+			call, isCall := ast.Unparen(a).(*ast.CallExpr)
+			if !isCall {
+				all = append(all, argType(c.pass.TypesInfo.TypeOf(a)))
+				exprs = append(exprs, a)
+				continue
+			}
+
+			call, failOnFalse, known := c.nestedCall(call)
+			chainFailOnFalse := c.failOnFalse
+			c.failOnFalse = failOnFalse
+
+			var returned piped
+			switch {
+			case !known:
+				returned = unknown
+			case c.is(call.Fun, "Call", "Call"):
+				returned = c.step(call, call.Args, in)
+			case c.is(call.Fun, "CallNamed", "CallNamed"):
+				returned = c.step(call, tail(call.Args), in)
+			case c.is(call.Fun, "Run", "Run"), c.is(call.Fun, "Fallback", "Fallback"),
+				c.is(call.Fun, "Race", "Race"), c.is(call.Fun, "Hedge", "Hedge"):
+				returned = unknown
+			default:
+				returned = piped{types: []types.Type{argType(c.pass.TypesInfo.TypeOf(a))}}
+			}
+			c.failOnFalse = chainFailOnFalse
+
+			if returned.unknown {
+				return nil, nil, false
+			}
+			for _, t := range returned.types {
+				all = append(all, t)
+				exprs = append(exprs, a)
+			}
+		}
+	}
+	return all, exprs, true
+}
+
+// argType returns the type of a value of the static type t, as it is passed to a function,
+// nil if the type is only known at run time
+func argType(t types.Type) types.Type {
+	if t == nil || types.IsInterface(t) {
+		return nil
+	}
+	if b, ok := t.(*types.Basic); ok && b.Info()&types.IsUntyped != 0 {
+		if b.Kind() == types.UntypedNil {
+			return nil
+		}
+		return types.Default(t)
+	}
+	return t
+}
+
+// returns returns the types of the values returned by a function with the signature sig,
+// without a final value of a type that implements error (or a final bool with FailOnFalse).
+// Values of interface types are piped with their dynamic types, so their types are unknown.
+func returns(sig *types.Signature, failOnFalse bool) piped {
+	res := sig.Results()
+	num := res.Len()
+	if num > 0 && reportsFailure(res.At(num-1).Type(), failOnFalse) {
+		num--
+	}
+	p := piped{types: make([]types.Type, num)}
+	for i := 0; i < num; i++ {
+		p.types[i] = argType(res.At(i).Type())
+	}
+	return p
+}
+
+// reportsFailure reports, if a final returned value of type t reports failures, like queue.reportsFailure
+func reportsFailure(t types.Type, failOnFalse bool) bool {
+	if types.Implements(t, errorType) {
+		return true
+	}
+	b, ok := t.Underlying().(*types.Basic)
+	return failOnFalse && ok && b.Kind() == types.Bool
+}
+
+// validateArgs validates the arguments (nil if unknown) of a function with the signature sig
+// like queue.validateArgs and returns the index of the invalid argument (-1 if the number is invalid)
+// and the error message
+func validateArgs(sig *types.Signature, args []types.Type) (int, string) {
+	params := sig.Params()
+	numIns := params.Len()
+	diff := len(args) - numIns
+
+	if diff != 0 {
+		if !sig.Variadic() {
+			return -1, fmt.Sprintf("func wants %d arguments, but gets %d", numIns, len(args))
+		}
+		if diff < -1 {
+			return -1, fmt.Sprintf("func wants at least %d arguments, but gets %d", numIns, len(args))
+		}
+	}
+
+	limit := numIns
+	if sig.Variadic() {
+		limit--
+	}
+
+	for i := 0; i < limit; i++ {
+		if msg := validateArg(i, args[i], params.At(i).Type()); msg != "" {
+			return i, msg
+		}
+	}
+
+	if !sig.Variadic() {
+		return -1, ""
+	}
+
+	should := params.At(numIns - 1).Type().(*types.Slice).Elem()
+	for j := numIns - 1; j < len(args); j++ {
+		if msg := validateArg(j, args[j], should); msg != "" {
+			return j, msg
+		}
+	}
+	return -1, ""
+}
+
+func validateArg(i int, is, should types.Type) string {
+	if is == nil || types.AssignableTo(is, should) {
+		return ""
+	}
+	return fmt.Sprintf("%d. argument is a %#v but should be a %#v", i+1, typeString(is), typeString(should))
+}
+
+// typeString returns the string of the type t as it is printed by the reflect package
+func typeString(t types.Type) string {
+	sig, ok := t.(*types.Signature)
+	if !ok {
+		return types.TypeString(t, func(p *types.Package) string { return p.Name() })
+	}
+
+	params := make([]string, sig.Params().Len())
+	for i := range params {
+		params[i] = typeString(sig.Params().At(i).Type())
+	}
+	if sig.Variadic() {
+		last := len(params) - 1
+		params[last] = "..." + typeString(sig.Params().At(last).Type().(*types.Slice).Elem())
+	}
+	s := "func(" + strings.Join(params, ", ") + ")"
+
+	results := make([]string, sig.Results().Len())
+	for i := range results {
+		results[i] = typeString(sig.Results().At(i).Type())
+	}
+	switch len(results) {
+	case 0:
+	case 1:
+		s += " " + results[0]
+	default:
+		s += " (" + strings.Join(results, ", ") + ")"
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package a
+
+import (
+	"context"
+	"strconv"
+
+	"gopkg.in/go-on/queue.v2"
+	"gopkg.in/go-on/queue.v2/q"
+)
+
+type Person struct{ Age int }
+
+func (p *Person) SetAge(age int) {}
+
+func get(key string) string { return key }
+
+func join(sep string, parts ...string) string { return sep }
+
+func two() (int, string) { return 0, "" }
+
+func withContext(ctx context.Context, s string) error { return nil }
+
+func setInt64(i int64) {}
+
+func lookup(key string) (int, bool) { return 0, true }
+
+func valid(p *Person, i interface{}) {
+	queue.New().Add(get, "Age").Add(strconv.Atoi, queue.PIPE).Add(p.SetAge, queue.PIPE).Run()
+	queue.Add(two).Add(func(int, string) {}, queue.PIPE).Run()
+	queue.Add(join, ",").Add(join, ",", "a", "b").Add(join, queue.PIPE, queue.PIPE).Run()
+	queue.Add(p.SetAge, queue.Call(strconv.Atoi, "1")).Run()
+	queue.Add(p.SetAge, 2).Add(get, "x").Tee(get, queue.PIPE).Add(strconv.Atoi, queue.PIPE).Run()
+	queue.Add(withContext, queue.CTX, "x").Run()
+
+	// unknown types are not checked
+	queue.Add(p.SetAge, i).Run()
+	queue.Add(p.SetAge, nil).Run()
+	queue.Add(queue.Value, "x").Add(get, queue.PIPE).Run()
+	queue.Add(get, queue.Fallback(queue.Add(get, "x"))).Run()
+	queue.New().Sub(queue.Add(get, "x")).Add(p.SetAge, queue.PIPE).Run()
+
+	// arguments might be converted
+	queue.New().SetConverter(queue.NewConverter()).Add(strconv.Atoi, "1").Add(setInt64, queue.PIPE).Run()
+	queue.Add(setInt64, 1).SetConverter(queue.NewConverter()).Run()
+
+	// the bool reports failures
+	queue.New().FailOn(queue.FailOnFalse).Add(lookup, "a").Add(p.SetAge, queue.PIPE).Run()
+	queue.Add(lookup, "a").Add(p.SetAge, queue.PIPE).FailOn(queue.FailOnFalse).Run()
+	queue.Add(p.SetAge, queue.Call(lookup, "a").FailOn(queue.FailOnFalse)).Run()
+	queue.Add(p.SetAge, queue.Call(strconv.Atoi, "1").OnError(nil)).Run()
+	queue.AddNamed("lookup", lookup, "a").Add(p.SetAge, queue.PIPE).FailOnAt("lookup", queue.FailOnFalse).Run()
+
+	// the nested queue might get piped values
+	queue.Add(p.SetAge, queue.PIPE)
+
+	q.Q(get, "Age")(strconv.Atoi, q.V)(p.SetAge, q.V).Run()
+}
+
+func invalid(p *Person, s string) {
+	queue.New().Add(get, "Age").Add(p.SetAge, queue.PIPE).Run()     // want `function "func\(int\)" gets invalid argument: 1. argument is a "string" but should be a "int"`
+	queue.Add(get, 1).Run()                                         // want `function "func\(string\) string" gets invalid argument: 1. argument is a "int" but should be a "string"`
+	queue.Add(get).Run()                                            // want `function "func\(string\) string" gets invalid argument: func wants 1 arguments, but gets 0`
+	queue.Add(join).Run()                                           // want `func wants at least 2 arguments, but gets 0`
+	queue.Add(join, ",", "a", 3).Run()                              // want `3. argument is a "int" but should be a "string"`
+	queue.Add(two).Add(get, queue.PIPE).Run()                       // want `func wants 1 arguments, but gets 2`
+	queue.Add(strconv.Atoi, "1").Add(get, queue.PIPE).Run()         // want `1. argument is a "int" but should be a "string"`
+	queue.Add(p.SetAge, queue.Call(get, "x")).Run()                 // want `1. argument is a "string" but should be a "int"`
+	queue.Add(get, "x").Tee(p.SetAge, queue.PIPE).Run()             // want `1. argument is a "string" but should be a "int"`
+	queue.Add(s).Run()                                              // want `function "string" is invalid: "string" is no func`
+	queue.AddNamed("name", p.SetAge, s).SetName("x").Run()          // want `1. argument is a "string" but should be a "int"`
+	queue.Add(p.SetAge, p).Run()                                    // want `1. argument is a "\*a.Person" but should be a "int"`
+	queue.Add(setInt64, 1).Run()                                    // want `1. argument is a "int" but should be a "int64"`
+	queue.Add(lookup, "a").Add(p.SetAge, queue.PIPE).Run()          // want `func wants 1 arguments, but gets 2`
+	queue.Add(get, "x").AutoClose().Add(p.SetAge, queue.PIPE).Run() // want `1. argument is a "string" but should be a "int"`
+
+	// the last FailOn() counts, the one of a nested call comes first
+	queue.Add(lookup, "a").Add(p.SetAge, queue.PIPE).FailOn(queue.FailOnFalse).FailOn(queue.FailOnError).Run()   // want `func wants 1 arguments, but gets 2`
+	queue.New().FailOn(queue.FailOnFalse).Add(p.SetAge, queue.Call(lookup, "a").FailOn(queue.FailOnError)).Run() // want `func wants 1 arguments, but gets 2`
+
+	// the first call is not checked, but the following ones are
+	queue.Add(strconv.Atoi, queue.PIPE).Add(get, queue.PIPE) // want `1. argument is a "int" but should be a "string"`
+
+	q.Q(get, "Age")(p.SetAge, q.V).Run()     // want `1. argument is a "string" but should be a "int"`
+	q.Q(get, "Age").Add(p.SetAge, q.V).Run() // want `1. argument is a "string" but should be a "int"`
+	q.Q(p.SetAge, q.Call(get, "x")).Run()    // want `1. argument is a "string" but should be a "int"`
+}
@@ -0,0 +1,18 @@
+// Package q is a stub of gopkg.in/go-on/queue.v2/q for the tests of the analyzer
+package q
+
+import "gopkg.in/go-on/queue.v2"
+
+var (
+	V    = queue.PIPE
+	Call = queue.Call
+)
+
+type QFunc func(fn interface{}, params ...interface{}) QFunc
+
+func (q QFunc) Queue() *queue.Queue                           { return nil }
+func (q QFunc) Add(fn interface{}, args ...interface{}) QFunc { return q }
+func (q QFunc) Tee(fn interface{}, args ...interface{}) QFunc { return q }
+func (q QFunc) Run() error                                    { return nil }
+func (q QFunc) CheckAndRun() error                            { return nil }
+func Q(function interface{}, arguments ...interface{}) QFunc  { return nil }
@@ -0,0 +1,72 @@
+// Package queue is a stub of gopkg.in/go-on/queue.v2 for the tests of the analyzer
+package queue
+
+import "context"
+
+type Queue struct{}
+
+type Queuer interface {
+	Queue() *Queue
+}
+
+type ErrHandler interface {
+	HandleError(error) error
+}
+
+type pipe struct{}
+type ctxArg struct{}
+type call struct{}
+type callrun []Queuer
+type callfallback []Queuer
+type callrace struct{}
+
+var (
+	PIPE = pipe{}
+	CTX  = ctxArg{}
+)
+
+func New() *Queue                                               { return nil }
+func OnError(handler ErrHandler) *Queue                         { return nil }
+func Add(function interface{}, arguments ...interface{}) *Queue { return nil }
+func AddNamed(name string, function interface{}, arguments ...interface{}) *Queue {
+	return nil
+}
+func Call(function interface{}, arguments ...interface{}) *call { return nil }
+func CallNamed(name string, function interface{}, arguments ...interface{}) *call {
+	return nil
+}
+func Value(i interface{}) interface{}    { return i }
+func Run(qs ...Queuer) callrun           { return nil }
+func Fallback(qs ...Queuer) callfallback { return nil }
+func Race(qs ...Queuer) callrace         { return callrace{} }
+
+func (q *Queue) Queue() *Queue                                             { return q }
+func (q *Queue) Add(function interface{}, arguments ...interface{}) *Queue { return q }
+func (q *Queue) AddNamed(name string, function interface{}, arguments ...interface{}) *Queue {
+	return q
+}
+func (q *Queue) Tee(function interface{}, arguments ...interface{}) *Queue { return q }
+func (q *Queue) Sub(qs ...Queuer) *Queue                                   { return q }
+func (q *Queue) SetName(name string) *Queue                                { return q }
+func (q *Queue) Run() error                                                { return nil }
+func (q *Queue) RunContext(ctx context.Context) error                      { return nil }
+func (q *Queue) Check() error                                              { return nil }
+
+type Converter struct{}
+
+func NewConverter() *Converter                    { return nil }
+func (q *Queue) SetConverter(c *Converter) *Queue { return q }
+
+type Failure int
+
+const (
+	FailOnError Failure = iota + 1
+	FailOnFalse
+)
+
+func (q *Queue) FailOn(f Failure) *Queue                { return q }
+func (q *Queue) FailOnAt(name string, f Failure) *Queue { return q }
+func (c *call) FailOn(f Failure) *call                  { return c }
+func (c *call) OnError(handler ErrHandler) *call        { return c }
+
+func (q *Queue) AutoClose(closers ...interface{}) *Queue { return q }
@@ -0,0 +1,49 @@
+// Command queuegen generates plain Go functions from queue definitions (see package queuegen).
+//
+// Usage:
+//
+//	queuegen -func setAge,loadPerson [-o queue_gen.go] [dir]
+//
+// It is meant to be used with go generate:
+//
+//	//go:generate queuegen -func setAge
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/go-on/queue.v2/queuegen"
+)
+
+func main() {
+	funcs := flag.String("func", "", "comma separated names of the queue definitions")
+	output := flag.String("o", "queue_gen.go", "name of the generated file, relative to dir")
+	flag.Parse()
+
+	if *funcs == "" {
+		fmt.Fprintln(os.Stderr, "queuegen: missing -func")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	var bf bytes.Buffer
+	if err := queuegen.Generate(&bf, dir, strings.Split(*funcs, ",")...); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, *output), bf.Bytes(), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,81 @@
+// Package example has queue definitions to test the code generated by queuegen
+package example
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/go-on/queue.v2"
+)
+
+//go:generate go run gopkg.in/go-on/queue.v2/queuegen/cmd/queuegen -func setAge,IgnoreErrors,withFallback,validate -o example_queue.go
+
+type Person struct {
+	Name string
+	Age  int
+}
+
+func (p *Person) SetAge(age int) { p.Age = age }
+
+func (p *Person) SetName(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty name")
+	}
+	p.Name = name
+	return nil
+}
+
+// ValidationError is an error of a custom type
+type ValidationError struct {
+	Field string
+}
+
+func (v *ValidationError) Error() string { return "invalid " + v.Field }
+
+func (p *Person) Validate() *ValidationError {
+	if p.Age < 18 {
+		return &ValidationError{"age"}
+	}
+	return nil
+}
+
+func get(key string, m map[string]string) string { return m[key] }
+
+func appendLog(log *[]string, vals ...string) {
+	*log = append(*log, vals...)
+}
+
+func setAge(p *Person, m map[string]string) *queue.Queue {
+	return queue.New().
+		Add(get, "Age", m).
+		Add(strconv.Atoi, queue.PIPE).
+		Add(p.SetAge, queue.PIPE)
+}
+
+func IgnoreErrors(p *Person, m map[string]string, log *[]string) *queue.Queue {
+	return queue.OnError(queue.IGNORE).
+		AddNamed("age", get, "Age", m).
+		Tee(appendLog, log, "age", queue.PIPE).
+		Add(strconv.Atoi, queue.PIPE).
+		Add(p.SetAge, queue.PIPE).
+		Add(p.SetName, queue.Call(get, "Name", m)).
+		Add(queue.Value, "done").
+		TeeNamed("log", appendLog, log, queue.PIPE)
+}
+
+func withFallback(p *Person, m map[string]string) *queue.Queue {
+	return queue.Add(get, "Age", m).
+		Add(p.SetAge, queue.Fallback(
+			queue.Add(strconv.Atoi, queue.PIPE),
+			queue.Add(get, "DefaultAge", m).Add(strconv.Atoi, queue.PIPE),
+		)).
+		Add(p.SetName, queue.CallNamed("name", get, "Name", m))
+}
+
+func validate(p *Person, m map[string]string) *queue.Queue {
+	return queue.Add(get, "Age", m).
+		Add(strconv.Atoi, queue.PIPE).
+		Add(p.SetAge, queue.PIPE).
+		Add(p.Validate).
+		Add(p.SetName, queue.Call(get, "Name", m))
+}
@@ -0,0 +1,101 @@
+// Code generated by queuegen; DO NOT EDIT.
+
+package example
+
+import (
+	"strconv"
+
+	"gopkg.in/go-on/queue.v2"
+)
+
+// runSetAge runs the queue of setAge without reflection.
+func runSetAge(p *Person, m map[string]string) (err error) {
+	v1 := get("Age", m)
+	v2, err := strconv.Atoi(v1)
+	if err != nil {
+		return
+	}
+	p.SetAge(v2)
+	return
+}
+
+// RunIgnoreErrors runs the queue of IgnoreErrors without reflection.
+func RunIgnoreErrors(p *Person, m map[string]string, log *[]string) (err error) {
+	errHandler := queue.IGNORE
+	v1 := get("Age", m)
+	appendLog(log, "age", v1)
+	v2, err := strconv.Atoi(v1)
+	if err != nil {
+		if err = errHandler.HandleError(err); err != nil {
+			return
+		}
+	}
+	p.SetAge(v2)
+	v3 := get("Name", m)
+	err = p.SetName(v3)
+	if err != nil {
+		if err = errHandler.HandleError(err); err != nil {
+			return
+		}
+	}
+	v4 := queue.Value("done")
+	appendLog(log, v4.(string))
+	return
+}
+
+// runWithFallback runs the queue of withFallback without reflection.
+func runWithFallback(p *Person, m map[string]string) (err error) {
+	v1 := get("Age", m)
+	fallback1 := func() (_ int, err error) {
+		v2, err := strconv.Atoi(v1)
+		if err != nil {
+			return
+		}
+		return v2, nil
+	}
+	fallback2 := func() (_ int, err error) {
+		v3 := get("DefaultAge", m)
+		v4, err := strconv.Atoi(v3)
+		if err != nil {
+			return
+		}
+		return v4, nil
+	}
+	v5, err := fallback1()
+	if err != nil {
+		v5, err = fallback2()
+	}
+	if err != nil {
+		return
+	}
+	p.SetAge(v5)
+	v6 := get("Name", m)
+	err = p.SetName(v6)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// runValidate runs the queue of validate without reflection.
+func runValidate(p *Person, m map[string]string) (err error) {
+	v1 := get("Age", m)
+	v2, err := strconv.Atoi(v1)
+	if err != nil {
+		return
+	}
+	p.SetAge(v2)
+	v3 := p.Validate()
+	if v3 != nil {
+		err = v3
+	}
+	if err != nil {
+		return
+	}
+	v4 := get("Name", m)
+	err = p.SetName(v4)
+	if err != nil {
+		return
+	}
+	return
+}
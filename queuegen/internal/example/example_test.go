@@ -0,0 +1,69 @@
+package example
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var inputs = []map[string]string{
+	{"Age": "42", "Name": "Peter"},
+	{"Age": "x42", "Name": "Peter"},
+	{"Age": "42"},
+	{"Age": "x", "DefaultAge": "7", "Name": "Paul"},
+	{"Age": "x", "DefaultAge": "y", "Name": "Paul"},
+	{"Age": "7", "Name": "Tim"},
+	{},
+}
+
+// compare runs the queue and the generated function with the same inputs and
+// compares the errors and the resulting persons and logs
+func compare(t *testing.T, name string, queue func(*Person, map[string]string, *[]string) error, generated func(*Person, map[string]string, *[]string) error) {
+	for i, m := range inputs {
+		var p1, p2 Person
+		var log1, log2 []string
+
+		err1 := queue(&p1, m, &log1)
+		err2 := generated(&p2, m, &log2)
+
+		if fmt.Sprint(err1) != fmt.Sprint(err2) {
+			t.Errorf("%s inputs[%d]: queue returns error %v, generated function %v", name, i, err1, err2)
+		}
+
+		if p1 != p2 {
+			t.Errorf("%s inputs[%d]: queue sets %#v, generated function %#v", name, i, p1, p2)
+		}
+
+		if !reflect.DeepEqual(log1, log2) {
+			t.Errorf("%s inputs[%d]: queue logs %#v, generated function %#v", name, i, log1, log2)
+		}
+	}
+}
+
+func TestSetAge(t *testing.T) {
+	compare(t, "setAge",
+		func(p *Person, m map[string]string, _ *[]string) error { return setAge(p, m).Run() },
+		func(p *Person, m map[string]string, _ *[]string) error { return runSetAge(p, m) },
+	)
+}
+
+func TestIgnoreErrors(t *testing.T) {
+	compare(t, "IgnoreErrors",
+		func(p *Person, m map[string]string, log *[]string) error { return IgnoreErrors(p, m, log).Run() },
+		RunIgnoreErrors,
+	)
+}
+
+func TestWithFallback(t *testing.T) {
+	compare(t, "withFallback",
+		func(p *Person, m map[string]string, _ *[]string) error { return withFallback(p, m).Run() },
+		func(p *Person, m map[string]string, _ *[]string) error { return runWithFallback(p, m) },
+	)
+}
+
+func TestValidate(t *testing.T) {
+	compare(t, "validate",
+		func(p *Person, m map[string]string, _ *[]string) error { return validate(p, m).Run() },
+		func(p *Person, m map[string]string, _ *[]string) error { return runValidate(p, m) },
+	)
+}
@@ -0,0 +1,623 @@
+// Copyright (c) 2014 Marc René Arns. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+/*
+Package queuegen generates plain Go functions from queues of gopkg.in/go-on/queue.v2,
+to get the readability of a queue without the cost of reflection.
+
+A queue definition is a function of the package that returns a queue chain
+
+	func setAge(p *Person, m map[string]string) *queue.Queue {
+		return queue.New().Add(get, "Age", m).Add(strconv.Atoi, queue.PIPE).Add(p.SetAge, queue.PIPE)
+	}
+
+For the definition, a function with the same parameters is generated, that behaves like
+running the queue via Run()
+
+	func runSetAge(p *Person, m map[string]string) (err error) {
+		v1 := get("Age", m)
+		v2, err := strconv.Atoi(v1)
+		if err != nil {
+			return
+		}
+		p.SetAge(v2)
+		return
+	}
+
+The name of the generated function is the name of the definition prefixed with "run"
+("Run" for exported definitions).
+
+The following parts of a queue are supported: New(), Add(), AddNamed(), OnError(),
+Tee(), TeeNamed(), SetName(), the pseudo argument PIPE and the arguments Call(), CallNamed()
+and Fallback() with queue chains as alternatives. Logging is not generated.
+Values of interface types that are piped into other types are converted with type assertions.
+
+In contrast to Run(), the generated functions don't recover panics, treat Halt and Return()
+like any other error and if the error handler catches the error of a Call() or Fallback()
+argument, the function is called with the zero values.
+*/
+package queuegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const queuePath = "gopkg.in/go-on/queue.v2"
+
+var errorType = types.Universe.Lookup("error").Type()
+
+var errorInterface = errorType.Underlying().(*types.Interface)
+
+// Generate loads the package in dir and writes a Go file with the generated functions of the queue
+// definitions with the given names to w.
+func Generate(w io.Writer, dir string, definitions ...string) error {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo,
+		Dir:  dir,
+	}
+	pkgs, err := packages.Load(cfg, ".")
+	if err != nil {
+		return err
+	}
+	if len(pkgs) != 1 {
+		return fmt.Errorf("queuegen: expecting one package in %s, but found %d", dir, len(pkgs))
+	}
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		return fmt.Errorf("queuegen: %s", pkg.Errors[0])
+	}
+
+	g := &generator{pkg: pkg, imports: map[string]importSpec{}}
+	var body bytes.Buffer
+	for _, name := range definitions {
+		decl := g.definition(name)
+		if decl == nil {
+			return fmt.Errorf("queuegen: no queue definition %s in %s", name, pkg.PkgPath)
+		}
+		if err := g.function(&body, decl); err != nil {
+			return err
+		}
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by queuegen; DO NOT EDIT.\n\npackage %s\n\n", pkg.Name)
+	src.WriteString(g.importDecl())
+	body.WriteTo(&src)
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return fmt.Errorf("queuegen: invalid generated code: %s\n%s", err, src.Bytes())
+	}
+	_, err = w.Write(formatted)
+	return err
+}
+
+type generator struct {
+	pkg *packages.Package
+
+	// imports of the generated file by path
+	imports map[string]importSpec
+
+	// counters for unique names inside a generated function
+	vars, handlers, fallbacks int
+}
+
+// definition returns the declaration of the queue definition with the given name
+func (g *generator) definition(name string) *ast.FuncDecl {
+	for _, f := range g.pkg.Syntax {
+		for _, d := range f.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv == nil && fd.Name.Name == name {
+				return fd
+			}
+		}
+	}
+	return nil
+}
+
+func (g *generator) errorf(pos token.Pos, format string, a ...interface{}) error {
+	return fmt.Errorf("queuegen: %s: %s", g.pkg.Fset.Position(pos), fmt.Sprintf(format, a...))
+}
+
+// importSpec is an import of the generated file
+type importSpec struct {
+	// local name of the import
+	name string
+
+	// name of the package
+	pkgName string
+}
+
+func (g *generator) importDecl() string {
+	if len(g.imports) == 0 {
+		return ""
+	}
+	var std, other []string
+	for p := range g.imports {
+		if strings.Contains(strings.SplitN(p, "/", 2)[0], ".") {
+			other = append(other, p)
+		} else {
+			std = append(std, p)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	if len(std) > 0 && len(other) > 0 {
+		std = append(std, "")
+	}
+
+	var bf bytes.Buffer
+	bf.WriteString("import (\n")
+	for _, p := range append(std, other...) {
+		spec, ok := g.imports[p]
+		switch {
+		case !ok:
+			bf.WriteString("\n")
+		case spec.name == spec.pkgName:
+			fmt.Fprintf(&bf, "\t%s\n", strconv.Quote(p))
+		default:
+			fmt.Fprintf(&bf, "\t%s %s\n", spec.name, strconv.Quote(p))
+		}
+	}
+	bf.WriteString(")\n\n")
+	return bf.String()
+}
+
+// source returns the source of the node n and registers the packages it references as imports
+func (g *generator) source(n ast.Node) string {
+	ast.Inspect(n, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := sel.X.(*ast.Ident); ok {
+			if pn, ok := g.pkg.TypesInfo.Uses[id].(*types.PkgName); ok {
+				g.imports[pn.Imported().Path()] = importSpec{id.Name, pn.Imported().Name()}
+			}
+		}
+		return true
+	})
+	var bf bytes.Buffer
+	format.Node(&bf, g.pkg.Fset, n)
+	return bf.String()
+}
+
+// params returns the source of the parameters of a function
+func (g *generator) params(fields *ast.FieldList) string {
+	var params []string
+	for _, f := range fields.List {
+		var names []string
+		for _, n := range f.Names {
+			names = append(names, n.Name)
+		}
+		params = append(params, strings.TrimSpace(strings.Join(names, ", ")+" "+g.source(f.Type)))
+	}
+	return "(" + strings.Join(params, ", ") + ")"
+}
+
+// typeString returns the source of the type t and registers the packages it references as imports
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == g.pkg.Types {
+			return ""
+		}
+		if _, ok := g.imports[p.Path()]; !ok {
+			g.imports[p.Path()] = importSpec{p.Name(), p.Name()}
+		}
+		return g.imports[p.Path()].name
+	})
+}
+
+// object returns the name of the package level object of the queue package referenced by e
+func (g *generator) object(e ast.Expr) string {
+	var id *ast.Ident
+	switch x := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		id = x
+	case *ast.SelectorExpr:
+		id = x.Sel
+	default:
+		return ""
+	}
+	obj := g.pkg.TypesInfo.Uses[id]
+	if obj == nil || obj.Pkg() == nil || obj.Pkg().Path() != queuePath || obj.Parent() != obj.Pkg().Scope() {
+		return ""
+	}
+	return obj.Name()
+}
+
+// chain is a parsed queue chain
+type chain struct {
+	// the error handler set via OnError(), nil for STOP
+	errHandler ast.Expr
+
+	steps []*step
+}
+
+// step is a call or tee of a chain
+type step struct {
+	tee  bool
+	fn   ast.Expr
+	args []ast.Expr
+}
+
+// parse parses the queue chain e
+func (g *generator) parse(e ast.Expr) (*chain, error) {
+	call, ok := ast.Unparen(e).(*ast.CallExpr)
+	if !ok {
+		return nil, g.errorf(e.Pos(), "%s is no queue chain", g.source(e))
+	}
+	if call.Ellipsis.IsValid() {
+		return nil, g.errorf(call.Ellipsis, "arguments passed with ... are not supported")
+	}
+
+	switch g.object(call.Fun) {
+	case "New":
+		return &chain{}, nil
+	case "OnError":
+		return &chain{errHandler: call.Args[0]}, nil
+	case "Add":
+		return &chain{steps: []*step{{fn: call.Args[0], args: call.Args[1:]}}}, nil
+	case "AddNamed":
+		return &chain{steps: []*step{{fn: call.Args[1], args: call.Args[2:]}}}, nil
+	}
+
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return nil, g.errorf(call.Pos(), "%s is no queue chain", g.source(call))
+	}
+	ch, err := g.parse(sel.X)
+	if err != nil {
+		return nil, err
+	}
+
+	switch sel.Sel.Name {
+	case "Add":
+		ch.steps = append(ch.steps, &step{fn: call.Args[0], args: call.Args[1:]})
+	case "AddNamed":
+		ch.steps = append(ch.steps, &step{fn: call.Args[1], args: call.Args[2:]})
+	case "Tee", "TeeNamed":
+		if len(ch.steps) == 0 {
+			return nil, g.errorf(call.Pos(), "tee before the first call is never run")
+		}
+		args := call.Args
+		if sel.Sel.Name == "TeeNamed" {
+			args = args[1:]
+		}
+		ch.steps = append(ch.steps, &step{tee: true, fn: args[0], args: args[1:]})
+	case "OnError":
+		ch.errHandler = call.Args[0]
+	case "SetName", "LogErrorsTo", "LogDebugTo":
+	default:
+		return nil, g.errorf(sel.Sel.Pos(), "%s is not supported by queuegen", sel.Sel.Name)
+	}
+	return ch, nil
+}
+
+// variable holds a value returned by a call
+type variable struct {
+	name string
+	typ  types.Type
+	used bool
+}
+
+func (v *variable) String() string {
+	if !v.used {
+		return "_"
+	}
+	return v.name
+}
+
+// assign is a call whose results are assigned to variables. It is rendered after the whole
+// function is generated, to know which variables are used
+type assign struct {
+	indent string
+	vars   []*variable
+	err    bool
+	call   string
+}
+
+func (a *assign) String() string {
+	var lhs []string
+	define := false
+	for _, v := range a.vars {
+		lhs = append(lhs, v.String())
+		define = define || v.used
+	}
+	if a.err {
+		lhs = append(lhs, "err")
+	}
+
+	switch {
+	case len(lhs) == 0 || (!define && !a.err):
+		return a.indent + a.call + "\n"
+	case define:
+		return a.indent + strings.Join(lhs, ", ") + " := " + a.call + "\n"
+	}
+	return a.indent + strings.Join(lhs, ", ") + " = " + a.call + "\n"
+}
+
+// body are the lines of a generated function, either strings or *assign
+type body []fmt.Stringer
+
+type line string
+
+func (l line) String() string { return string(l) }
+
+func (b *body) printf(indent string, format string, a ...interface{}) {
+	*b = append(*b, line(indent+fmt.Sprintf(format, a...)+"\n"))
+}
+
+// function writes the generated function for the definition decl to w
+func (g *generator) function(w io.Writer, decl *ast.FuncDecl) error {
+	res := g.pkg.TypesInfo.Defs[decl.Name].Type().(*types.Signature).Results()
+	if res.Len() != 1 || res.At(0).Type().String() != "*"+queuePath+".Queue" {
+		return g.errorf(decl.Pos(), "queue definition %s must return a *queue.Queue", decl.Name.Name)
+	}
+	if len(decl.Body.List) != 1 {
+		return g.errorf(decl.Pos(), "queue definition %s must only return a queue chain", decl.Name.Name)
+	}
+	ret, ok := decl.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return g.errorf(decl.Pos(), "queue definition %s must only return a queue chain", decl.Name.Name)
+	}
+	ch, err := g.parse(ret.Results[0])
+	if err != nil {
+		return err
+	}
+
+	g.vars, g.handlers, g.fallbacks = 0, 0, 0
+	var b body
+	if _, err := g.queue(&b, ch, nil, "\t"); err != nil {
+		return err
+	}
+	b.printf("\t", "return")
+
+	name := decl.Name.Name
+	prefix := "run"
+	if ast.IsExported(name) {
+		prefix = "Run"
+	}
+	name = prefix + strings.ToUpper(name[:1]) + name[1:]
+
+	fmt.Fprintf(w, "// %s runs the queue of %s without reflection.\n", name, decl.Name.Name)
+	fmt.Fprintf(w, "func %s%s (err error) {\n", name, g.params(decl.Type.Params))
+	for _, l := range b {
+		io.WriteString(w, l.String())
+	}
+	fmt.Fprintf(w, "}\n\n")
+	return nil
+}
+
+// handle writes the error handling with the given error handler variable ("" for STOP)
+func (b *body) handle(indent string, errHandler string) {
+	b.printf(indent, "if err != nil {")
+	if errHandler == "" {
+		b.printf(indent, "\treturn")
+	} else {
+		b.printf(indent, "\tif err = %s.HandleError(err); err != nil {", errHandler)
+		b.printf(indent, "\t\treturn")
+		b.printf(indent, "\t}")
+	}
+	b.printf(indent, "}")
+}
+
+// queue writes the calls of the chain ch that gets the piped variables to b
+// and returns the variables of the values returned by the last call
+func (g *generator) queue(b *body, ch *chain, piped []*variable, indent string) ([]*variable, error) {
+	errHandler := ""
+	if ch.errHandler != nil {
+		g.handlers++
+		errHandler = "errHandler"
+		if g.handlers > 1 {
+			errHandler += strconv.Itoa(g.handlers)
+		}
+		b.printf(indent, "%s := %s", errHandler, g.source(ch.errHandler))
+	}
+
+	for _, st := range ch.steps {
+		returned, err := g.call(b, st.fn, st.args, piped, indent, errHandler)
+		if err != nil {
+			return nil, err
+		}
+		if !st.tee {
+			piped = returned
+		}
+	}
+	return piped, nil
+}
+
+// call writes the call of fn with the given arguments to b and returns the variables of the
+// returned values
+func (g *generator) call(b *body, fn ast.Expr, args []ast.Expr, piped []*variable, indent, errHandler string) ([]*variable, error) {
+	sig, ok := g.pkg.TypesInfo.TypeOf(fn).Underlying().(*types.Signature)
+	if !ok {
+		return nil, g.errorf(fn.Pos(), "%s is no func", g.source(fn))
+	}
+
+	var all []string
+	var allTypes []types.Type
+	add := func(src string, t types.Type) {
+		all = append(all, src)
+		allTypes = append(allTypes, t)
+	}
+
+	for _, a := range args {
+		switch g.object(a) {
+		case "PIPE":
+			for _, v := range piped {
+				v.used = true
+				add(v.name, v.typ)
+			}
+			continue
+		case "CTX":
+			return nil, g.errorf(a.Pos(), "CTX is not supported by queuegen")
+		}
+
+		call, isCall := ast.Unparen(a).(*ast.CallExpr)
+		if !isCall {
+			add(g.source(a), g.pkg.TypesInfo.TypeOf(a))
+			continue
+		}
+
+		var returned []*variable
+		var err error
+		switch name := g.object(call.Fun); name {
+		case "Call":
+			returned, err = g.call(b, call.Args[0], call.Args[1:], piped, indent, errHandler)
+		case "CallNamed":
+			returned, err = g.call(b, call.Args[1], call.Args[2:], piped, indent, errHandler)
+		case "Fallback":
+			returned, err = g.fallback(b, call.Args, piped, indent, errHandler)
+		case "Run", "Race", "Hedge":
+			err = g.errorf(a.Pos(), "%s() is not supported by queuegen", name)
+		default:
+			add(g.source(a), g.pkg.TypesInfo.TypeOf(a))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range returned {
+			v.used = true
+			add(v.name, v.typ)
+		}
+	}
+
+	// values of interface types are passed with their dynamic types
+	params := sig.Params()
+	for i, t := range allTypes {
+		var should types.Type
+		switch {
+		case sig.Variadic() && i >= params.Len()-1:
+			should = params.At(params.Len() - 1).Type().(*types.Slice).Elem()
+		case i < params.Len():
+			should = params.At(i).Type()
+		default:
+			continue
+		}
+		if t != nil && types.IsInterface(t) && !types.AssignableTo(t, should) {
+			all[i] = fmt.Sprintf("%s.(%s)", all[i], g.typeString(should))
+		}
+	}
+
+	a := &assign{indent: indent, call: g.source(fn) + "(" + strings.Join(all, ", ") + ")"}
+	res := sig.Results()
+	num := res.Len()
+	var customErr types.Type
+	if num > 0 && types.Implements(res.At(num-1).Type(), errorInterface) {
+		num--
+		if types.Identical(res.At(num).Type(), errorType) {
+			a.err = true
+		} else {
+			customErr = res.At(num).Type()
+		}
+	}
+	for i := 0; i < num; i++ {
+		g.vars++
+		a.vars = append(a.vars, &variable{name: "v" + strconv.Itoa(g.vars), typ: res.At(i).Type()})
+	}
+	if customErr == nil {
+		*b = append(*b, a)
+		if a.err {
+			b.handle(indent, errHandler)
+		}
+		return a.vars, nil
+	}
+
+	// a nil value of a custom error type is not nil as error, so it is checked before
+	// it is assigned to err
+	if !nilable(customErr) {
+		return nil, g.errorf(fn.Pos(), "returned error type %s can't be nil and is not supported by queuegen", g.typeString(customErr))
+	}
+	g.vars++
+	e := &variable{name: "v" + strconv.Itoa(g.vars), typ: customErr, used: true}
+	returned := a.vars
+	a.vars = append(a.vars, e)
+	*b = append(*b, a)
+	b.printf(indent, "if %s != nil {", e.name)
+	b.printf(indent, "	err = %s", e.name)
+	b.printf(indent, "}")
+	b.handle(indent, errHandler)
+	return returned, nil
+}
+
+func nilable(t types.Type) bool {
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Interface, *types.Map, *types.Slice, *types.Chan, *types.Signature:
+		return true
+	}
+	return false
+}
+
+// fallback writes the alternatives of a Fallback() argument as closures to b, followed by
+// their calls until one of them succeeds. It returns the variables of the returned values.
+func (g *generator) fallback(b *body, alternatives []ast.Expr, piped []*variable, indent, errHandler string) ([]*variable, error) {
+	if len(alternatives) == 0 {
+		return nil, nil
+	}
+
+	var names []string
+	var returned []*variable
+	for k, alt := range alternatives {
+		ch, err := g.parse(alt)
+		if err != nil {
+			return nil, err
+		}
+
+		g.fallbacks++
+		name := "fallback" + strconv.Itoa(g.fallbacks)
+		names = append(names, name)
+
+		var inner body
+		out, err := g.queue(&inner, ch, piped, indent+"\t")
+		if err != nil {
+			return nil, err
+		}
+		if k == 0 {
+			returned = out
+		} else if len(out) != len(returned) {
+			return nil, g.errorf(alt.Pos(), "Fallback alternative returns %d values, but should return %d", len(out), len(returned))
+		}
+
+		// the named error result is returned by the error handling
+		results := ""
+		vals := ""
+		for i, v := range out {
+			v.used = true
+			results += "_ " + g.typeString(returned[i].typ) + ", "
+			vals += v.name + ", "
+		}
+		b.printf(indent, "%s := func() (%serr error) {", name, results)
+		*b = append(*b, inner...)
+		b.printf(indent, "\treturn %snil", vals)
+		b.printf(indent, "}")
+	}
+
+	vars := make([]*variable, len(returned))
+	lhs := ""
+	for i, v := range returned {
+		g.vars++
+		vars[i] = &variable{name: "v" + strconv.Itoa(g.vars), typ: v.typ, used: true}
+		lhs += vars[i].name + ", "
+	}
+	b.printf(indent, "%serr := %s()", lhs, names[0])
+	for _, name := range names[1:] {
+		b.printf(indent, "if err != nil {")
+		b.printf(indent, "\t%serr = %s()", lhs, name)
+		b.printf(indent, "}")
+	}
+	b.handle(indent, errHandler)
+	return vars, nil
+}
@@ -0,0 +1,42 @@
+package queuegen
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	var bf bytes.Buffer
+	err := Generate(&bf, "internal/example", "setAge", "IgnoreErrors", "withFallback", "validate")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected, err := os.ReadFile("internal/example/example_queue.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bf.String() != string(expected) {
+		t.Errorf("generated code differs from internal/example/example_queue.go, run go generate:\n%s", bf.String())
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		definition string
+		msg        string
+	}{
+		{"missing", "no queue definition missing"},
+		{"get", "must return a *queue.Queue"},
+	}
+
+	for i, tt := range tests {
+		err := Generate(&bytes.Buffer{}, "internal/example", tt.definition)
+		if err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("tests[%d]: error should contain %#v, but is %v", i, tt.msg, err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Command queuerun checks, explains, graphs and runs declarative queues (see package queuerun)
+// with some functions of the standard library.
+//
+// It is meant as template: teams copy it and register their own functions.
+//
+// Usage:
+//
+//	queuerun run -in '"42"' definition.json
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/go-on/queue.v2"
+	"gopkg.in/go-on/queue.v2/queuerun"
+)
+
+func main() {
+	r := queue.NewRegistry().
+		Register("value", queue.Value).
+		Register("atoi", strconv.Atoi).
+		Register("itoa", strconv.Itoa).
+		Register("parseFloat", strconv.ParseFloat).
+		Register("parseBool", strconv.ParseBool).
+		Register("quote", strconv.Quote).
+		Register("toUpper", strings.ToUpper).
+		Register("toLower", strings.ToLower).
+		Register("trimSpace", strings.TrimSpace).
+		Register("split", strings.Split).
+		Register("join", strings.Join).
+		Register("repeat", strings.Repeat).
+		Register("replaceAll", strings.ReplaceAll).
+		Register("sprint", fmt.Sprint).
+		Register("sprintf", fmt.Sprintf).
+		Register("println", fmt.Println)
+	queuerun.Main(r)
+}
@@ -0,0 +1,260 @@
+// Package queuerun provides a command line interface to check, explain, graph and run
+// declarative queues (see queue.QueueDef) with the functions of a queue.Registry.
+//
+// Since the functions have to be compiled in, every team builds its own binary:
+//
+//	func main() {
+//		r := queue.NewRegistry().
+//			Register("get", get).
+//			Register("atoi", strconv.Atoi)
+//		queuerun.Main(r)
+//	}
+//
+// The binary is used like
+//
+//	queuerun check   [-in JSON]... [-stdin] definition
+//	queuerun explain [-in JSON]... [-stdin] definition
+//	queuerun graph   [-format dot|mermaid] definition
+//	queuerun run     [-in JSON]... [-stdin] [-timeout duration] [-log] definition
+//
+// The definition is a JSON or (if the file name ends with .yaml or .yml) YAML file,
+// "-" reads a JSON definition from stdin.
+//
+// Input values are piped to the first call of the queue. They are given as JSON, either
+// one value per -in flag or as JSON array via stdin. The values are decoded into the
+// types of the parameters of the first call that gets them via PIPE, other values are
+// decoded as plain JSON values (float64, string, bool, []interface{} or map[string]interface{}).
+//
+// The run subcommand writes the final piped values, the error and the duration of the run
+// as JSON to stdout, e.g.
+//
+//	{"values":[42],"duration":"12.5µs"}
+package queuerun
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/go-on/queue.v2"
+	"gopkg.in/go-on/queue.v2/internal/input"
+	"gopkg.in/go-on/queue.v2/yamlq"
+)
+
+// Main runs the command line interface with the functions and error handlers of r
+// and exits with its exit code
+func Main(r *queue.Registry) {
+	os.Exit(Run(r, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+const usage = `usage:
+	queuerun check   [-in JSON]... [-stdin] definition
+	queuerun explain [-in JSON]... [-stdin] definition
+	queuerun graph   [-format dot|mermaid] definition
+	queuerun run     [-in JSON]... [-stdin] [-timeout duration] [-log] definition
+`
+
+// Run runs the subcommand of args (without the program name) and returns the exit code:
+// 0 on success, 1 if the queue is invalid or its run fails and 2 for invalid usage.
+func Run(r *queue.Registry, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	cmd := &command{name: args[0], registry: r, stdin: stdin, stdout: stdout, stderr: stderr}
+	fs := flag.NewFlagSet("queuerun "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	switch cmd.name {
+	case "check", "explain", "run":
+		fs.Var(&cmd.input, "in", "JSON of an input value, may be repeated")
+		fs.BoolVar(&cmd.inputFromStdin, "stdin", false, "read the input values as JSON array from stdin")
+	case "graph":
+		fs.StringVar(&cmd.format, "format", "dot", "format of the graph: dot or mermaid")
+	default:
+		fmt.Fprintf(stderr, "unknown command %#v\n%s", cmd.name, usage)
+		return 2
+	}
+	if cmd.name == "run" {
+		fs.DurationVar(&cmd.timeout, "timeout", 0, "stop the run after the given duration")
+		fs.BoolVar(&cmd.log, "log", false, "write the debug log of the run to stderr")
+	}
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(stderr, "missing definition\n%s", usage)
+		return 2
+	}
+	cmd.file = fs.Arg(0)
+
+	if err := cmd.run(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// inputFlag collects the values of the repeated -in flag
+type inputFlag []json.RawMessage
+
+func (i *inputFlag) String() string { return fmt.Sprint(*i) }
+
+func (i *inputFlag) Set(s string) error {
+	if !json.Valid([]byte(s)) {
+		return fmt.Errorf("invalid JSON: %s", s)
+	}
+	*i = append(*i, json.RawMessage(s))
+	return nil
+}
+
+type command struct {
+	name           string
+	registry       *queue.Registry
+	stdin          io.Reader
+	stdout, stderr io.Writer
+
+	file           string
+	input          inputFlag
+	inputFromStdin bool
+	format         string
+	timeout        time.Duration
+	log            bool
+}
+
+func (c *command) run() error {
+	def, err := c.definition()
+	if err != nil {
+		return err
+	}
+
+	if c.inputFromStdin {
+		if c.file == "-" {
+			return fmt.Errorf("can't read the definition and the input values from stdin")
+		}
+		var in []json.RawMessage
+		if err := json.NewDecoder(c.stdin).Decode(&in); err != nil {
+			return fmt.Errorf("can't read input values: %s", err)
+		}
+		c.input = append(c.input, in...)
+	}
+
+	// the types of the input values are taken from the queue, that is checked with them later
+	q, err := c.registry.Build(def)
+	if err != nil {
+		return err
+	}
+	values, err := input.Decode(c.input, input.Types(q, len(c.input)), "input value")
+	if err != nil {
+		return err
+	}
+	types := make([]reflect.Type, len(values))
+	for i, v := range values {
+		types[i] = reflect.TypeOf(v)
+		if v == nil {
+			types[i] = reflect.TypeOf(&values[i]).Elem()
+		}
+	}
+
+	switch c.name {
+	case "check":
+		if _, err := c.registry.LoadWithInput(def, types...); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.stdout, "ok")
+		return nil
+	case "explain":
+		return q.Explain(c.stdout, types...)
+	case "graph":
+		switch c.format {
+		case "dot":
+			return q.WriteDOT(c.stdout)
+		case "mermaid":
+			return q.WriteMermaid(c.stdout)
+		}
+		return fmt.Errorf("unknown graph format %#v", c.format)
+	}
+
+	q, err = c.registry.LoadWithInput(def, types...)
+	if err != nil {
+		return err
+	}
+	return c.runQueue(q, values)
+}
+
+// definition reads the definition of the queue from the file
+func (c *command) definition() (*queue.QueueDef, error) {
+	var data []byte
+	var err error
+	if c.file == "-" {
+		data, err = io.ReadAll(c.stdin)
+	} else {
+		data, err = os.ReadFile(c.file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var def queue.QueueDef
+	switch strings.ToLower(filepath.Ext(c.file)) {
+	case ".yaml", ".yml":
+		err = yamlq.Unmarshal(data, &def)
+	default:
+		err = json.Unmarshal(data, &def)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read definition %s: %s", c.file, err)
+	}
+	return &def, nil
+}
+
+// report is the result of the run subcommand
+type report struct {
+	Values   []interface{} `json:"values"`
+	Error    string        `json:"error,omitempty"`
+	Duration string        `json:"duration"`
+}
+
+func (c *command) runQueue(q *queue.Queue, input []interface{}) error {
+	if c.log {
+		q.LogDebugTo(c.stderr)
+	}
+
+	ctx := context.Background()
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	values, err := q.RunWithInput(ctx, input...)
+	rep := report{Values: []interface{}{}, Duration: time.Since(start).String()}
+	for _, v := range values {
+		// values that can't be written as JSON, are written as text
+		if _, e := json.Marshal(v); e != nil {
+			v = fmt.Sprintf("%v", v)
+		}
+		rep.Values = append(rep.Values, v)
+	}
+	if err != nil {
+		rep.Error = err.Error()
+	}
+
+	if e := json.NewEncoder(c.stdout).Encode(rep); e != nil {
+		return e
+	}
+	if err != nil {
+		return fmt.Errorf("run failed")
+	}
+	return nil
+}
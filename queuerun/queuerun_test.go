@@ -0,0 +1,141 @@
+package queuerun
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/go-on/queue.v2"
+)
+
+func double(i int) int { return i * 2 }
+
+func add(a, b int) int { return a + b }
+
+func testRegistry() *queue.Registry {
+	return queue.NewRegistry().
+		Register("atoi", strconv.Atoi).
+		Register("itoa", strconv.Itoa).
+		Register("double", double).
+		Register("add", add).
+		Register("toUpper", strings.ToUpper)
+}
+
+const (
+	pipeline = `{"name": "pipeline", "steps": [
+  {"func": "atoi", "args": [{"pipe": true}]},
+  {"func": "double", "args": [{"pipe": true}]}
+]}`
+
+	sum = `steps:
+  - func: add
+    args: [{pipe: true}]
+  - func: itoa
+    args: [{pipe: true}]
+`
+
+	invalid = `{"steps": [{"func": "toUpper", "args": [1]}, {"func": "double", "args": [{"pipe": true}]}]}`
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func run(args []string, stdin string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	code = Run(testRegistry(), args, strings.NewReader(stdin), &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestRun(t *testing.T) {
+	jsonFile := writeFile(t, "pipeline.json", pipeline)
+	yamlFile := writeFile(t, "sum.yaml", sum)
+
+	tests := []struct {
+		args   []string
+		stdin  string
+		values string
+	}{
+		{[]string{"run", "-in", `"21"`, jsonFile}, "", `[42]`},
+		{[]string{"run", "-stdin", yamlFile}, `[3, 4]`, `["7"]`},
+		{[]string{"run", "-in", "3", "-stdin", yamlFile}, `[5]`, `["8"]`},
+		{[]string{"run", "-in", `"2"`, "-"}, pipeline, `[4]`},
+	}
+
+	for i, tt := range tests {
+		code, stdout, stderr := run(tt.args, tt.stdin)
+		if code != 0 {
+			t.Errorf("tests[%d]: exit code %d: %s", i, code, stderr)
+			continue
+		}
+		var rep struct {
+			Values json.RawMessage
+			Error  string
+		}
+		if err := json.Unmarshal([]byte(stdout), &rep); err != nil {
+			t.Errorf("tests[%d]: invalid output %#v", i, stdout)
+			continue
+		}
+		if string(rep.Values) != tt.values || rep.Error != "" {
+			t.Errorf("tests[%d]: values should be %s, but output is %s", i, tt.values, stdout)
+		}
+	}
+}
+
+func TestRunError(t *testing.T) {
+	file := writeFile(t, "pipeline.json", pipeline)
+	code, stdout, _ := run([]string{"run", "-in", `"x"`, file}, "")
+	if code != 1 || !strings.Contains(stdout, `"error":"strconv.Atoi: parsing \"x\": invalid syntax"`) {
+		t.Errorf("unexpected result %d: %s", code, stdout)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	valid := writeFile(t, "pipeline.json", pipeline)
+	invalid := writeFile(t, "invalid.json", invalid)
+
+	if code, stdout, stderr := run([]string{"check", "-in", `"1"`, valid}, ""); code != 0 || stdout != "ok\n" {
+		t.Errorf("unexpected result %d: %s%s", code, stdout, stderr)
+	}
+	if code, _, stderr := run([]string{"check", valid}, ""); code != 1 || !strings.Contains(stderr, "func wants 1 arguments, but gets 0") {
+		t.Errorf("unexpected result %d: %s", code, stderr)
+	}
+	if code, _, stderr := run([]string{"check", invalid}, ""); code != 1 || !strings.Contains(stderr, "can't decode argument 1") {
+		t.Errorf("unexpected result %d: %s", code, stderr)
+	}
+}
+
+func TestExplainAndGraph(t *testing.T) {
+	file := writeFile(t, "pipeline.json", pipeline)
+
+	_, stdout, _ := run([]string{"explain", "-in", `"1"`, file}, "")
+	if !strings.Contains(stdout, `queue "pipeline"`) || !strings.Contains(stdout, "string (PIPE)") {
+		t.Errorf("unexpected explanation:\n%s", stdout)
+	}
+
+	_, stdout, _ = run([]string{"graph", file}, "")
+	if !strings.HasPrefix(stdout, `digraph "pipeline" {`) {
+		t.Errorf("unexpected DOT graph:\n%s", stdout)
+	}
+
+	_, stdout, _ = run([]string{"graph", "-format", "mermaid", file}, "")
+	if !strings.HasPrefix(stdout, "flowchart TD") {
+		t.Errorf("unexpected Mermaid graph:\n%s", stdout)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for i, args := range [][]string{nil, {"unknown"}, {"run"}, {"graph", "-in", "1", "x.json"}} {
+		if code, _, _ := run(args, ""); code != 2 {
+			t.Errorf("tests[%d]: exit code should be 2, but is %d", i, code)
+		}
+	}
+}
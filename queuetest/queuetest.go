@@ -0,0 +1,302 @@
+// Package queuetest provides helpers for testing code that builds queues of gopkg.in/go-on/queue.v2:
+//
+//   - Spy and Fake record the calls of functions of any signature
+//   - Probe records the named steps of the runs of a queue and injects failures and panics
+//     into them, without changing the queue
+//   - AssertCallPanic, AssertInvalidArgument and AssertInvalidFunc check errors by StepPath
+//
+// A test might look like
+//
+//	fetch := queuetest.Fake(http.Get, nil, errors.New("offline"))
+//	q := buildQueue(fetch.Func().(func(string) (*http.Response, error)))
+//	p := queuetest.Attach(q, "fetch", "parse", "store").FailAt("parse", io.ErrUnexpectedEOF)
+//
+//	err := q.Run()
+//
+//	p.AssertOrder(t, "fetch", "parse")
+//	p.AssertNotCalled(t, "store")
+package queuetest
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"gopkg.in/go-on/queue.v2"
+)
+
+// SpyCall is a recorded call of a Spy
+type SpyCall struct {
+	Args    []interface{}
+	Returns []interface{}
+}
+
+// Spy wraps a function and records its calls
+type Spy struct {
+	fn      reflect.Value
+	wrapper reflect.Value
+
+	mu    sync.Mutex
+	calls []SpyCall
+}
+
+// NewSpy returns a Spy for the function fn. It panics if fn is no func.
+func NewSpy(fn interface{}) *Spy {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		panic(fmt.Sprintf("%T is no func", fn))
+	}
+
+	s := &Spy{fn: v}
+	s.wrapper = reflect.MakeFunc(v.Type(), func(args []reflect.Value) []reflect.Value {
+		var returns []reflect.Value
+		if v.Type().IsVariadic() {
+			returns = s.fn.CallSlice(args)
+		} else {
+			returns = s.fn.Call(args)
+		}
+		s.mu.Lock()
+		s.calls = append(s.calls, SpyCall{Args: interfaces(args), Returns: interfaces(returns)})
+		s.mu.Unlock()
+		return returns
+	})
+	return s
+}
+
+// Fake returns a Spy for a function with the signature of fn, that returns the given values
+// instead of calling fn. Missing values are zero values, nil values are nil.
+// It panics if fn is no func or the values don't fit the results of fn.
+func Fake(fn interface{}, returns ...interface{}) *Spy {
+	ftype := reflect.TypeOf(fn)
+	if ftype == nil || ftype.Kind() != reflect.Func {
+		panic(fmt.Sprintf("%T is no func", fn))
+	}
+	if len(returns) > ftype.NumOut() {
+		panic(fmt.Sprintf("%s returns %d values, but %d are given", ftype, ftype.NumOut(), len(returns)))
+	}
+
+	vals := make([]reflect.Value, ftype.NumOut())
+	for i := range vals {
+		vals[i] = reflect.New(ftype.Out(i)).Elem()
+		if i < len(returns) && returns[i] != nil {
+			v := reflect.ValueOf(returns[i])
+			if !v.Type().AssignableTo(ftype.Out(i)) {
+				panic(fmt.Sprintf("%d. value %T is not assignable to %s", i+1, returns[i], ftype.Out(i)))
+			}
+			vals[i].Set(v)
+		}
+	}
+
+	return NewSpy(reflect.MakeFunc(ftype, func([]reflect.Value) []reflect.Value {
+		return vals
+	}).Interface())
+}
+
+// Func returns the function that records its calls. It has the signature of the spied function.
+func (s *Spy) Func() interface{} {
+	return s.wrapper.Interface()
+}
+
+// Calls returns the recorded calls
+func (s *Spy) Calls() []SpyCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SpyCall(nil), s.calls...)
+}
+
+// Reset removes the recorded calls
+func (s *Spy) Reset() {
+	s.mu.Lock()
+	s.calls = nil
+	s.mu.Unlock()
+}
+
+func interfaces(vals []reflect.Value) []interface{} {
+	res := make([]interface{}, len(vals))
+	for i, v := range vals {
+		res[i] = v.Interface()
+	}
+	return res
+}
+
+// Step is a step of a run that was reached and recorded by a Probe
+type Step struct {
+	Path queue.StepPath
+	Name string
+
+	// the resolved arguments of the step
+	Args []interface{}
+}
+
+// Probe records the steps with the given names, when they are reached in the runs of a queue,
+// and injects failures and panics into steps, using a queue.Debugger.
+type Probe struct {
+	debugger *queue.Debugger
+
+	mu    sync.Mutex
+	steps []Step
+}
+
+// Attach attaches a Probe that records the steps with the given names to q.
+// It replaces the debugger of q.
+func Attach(q *queue.Queue, names ...string) *Probe {
+	p := &Probe{debugger: queue.NewDebugger()}
+	for _, name := range names {
+		p.debugger.BreakNamed(name, p.record)
+	}
+	q.SetDebugger(p.debugger)
+	return p
+}
+
+func (p *Probe) record(s *queue.DebugStep) {
+	p.mu.Lock()
+	p.steps = append(p.steps, Step{Path: s.Path, Name: s.Name, Args: s.Args})
+	p.mu.Unlock()
+}
+
+// FailAt replaces the steps with the given name, so that they return err instead of being called.
+// The error is handled by the error handlers like a returned error.
+func (p *Probe) FailAt(name string, err error) *Probe {
+	p.debugger.BreakNamed(name, func(s *queue.DebugStep) { s.Fail(err) })
+	return p
+}
+
+// PanicAt replaces the steps with the given name, so that they panic with v instead of being called.
+// The panic is returned as queue.CallPanic.
+func (p *Probe) PanicAt(name string, v interface{}) *Probe {
+	p.debugger.BreakNamed(name, func(*queue.DebugStep) { panic(v) })
+	return p
+}
+
+// Steps returns the recorded steps in the order they were reached
+func (p *Probe) Steps() []Step {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Step(nil), p.steps...)
+}
+
+// Calls returns the recorded steps with the given name
+func (p *Probe) Calls(name string) (steps []Step) {
+	for _, s := range p.Steps() {
+		if s.Name == name {
+			steps = append(steps, s)
+		}
+	}
+	return
+}
+
+// Reset removes the recorded steps
+func (p *Probe) Reset() {
+	p.mu.Lock()
+	p.steps = nil
+	p.mu.Unlock()
+}
+
+// AssertOrder checks that the recorded steps have the given names in the given order
+func (p *Probe) AssertOrder(t testing.TB, names ...string) {
+	t.Helper()
+	steps := p.Steps()
+	got := make([]string, len(steps))
+	for i, s := range steps {
+		got[i] = s.Name
+	}
+	if !reflect.DeepEqual(got, names) && (len(got) > 0 || len(names) > 0) {
+		t.Errorf("steps should be reached in order %#v, but are reached in order %#v", names, got)
+	}
+}
+
+// AssertCalledWith checks that a step with the given name was reached with the given arguments
+func (p *Probe) AssertCalledWith(t testing.TB, name string, args ...interface{}) {
+	t.Helper()
+	calls := p.Calls(name)
+	for _, s := range calls {
+		if reflect.DeepEqual(s.Args, args) || (len(s.Args) == 0 && len(args) == 0) {
+			return
+		}
+	}
+	if len(calls) == 0 {
+		t.Errorf("step %#v should be called with %#v, but is not called", name, args)
+		return
+	}
+	got := make([][]interface{}, len(calls))
+	for i, s := range calls {
+		got[i] = s.Args
+	}
+	t.Errorf("step %#v should be called with %#v, but is called with %#v", name, args, got)
+}
+
+// AssertNotCalled checks that no step with the given name was reached
+func (p *Probe) AssertNotCalled(t testing.TB, name string) {
+	t.Helper()
+	if calls := p.Calls(name); len(calls) > 0 {
+		t.Errorf("step %#v should not be called, but is called %d times", name, len(calls))
+	}
+}
+
+// AssertCallPanic checks that err is a queue.CallPanic of the step at the given path and returns it.
+// The path is only known if the run has a debugger (see Attach()).
+func AssertCallPanic(t testing.TB, err error, path queue.StepPath) queue.CallPanic {
+	t.Helper()
+	cp, ok := err.(queue.CallPanic)
+	if !ok {
+		t.Errorf("expecting queue.CallPanic at %s, but got %T: %v", path, err, err)
+		return cp
+	}
+	if cp.Path != path {
+		t.Errorf("expecting queue.CallPanic at %s, but got one at %s: %v", path, cp.Path, err)
+	}
+	return cp
+}
+
+// AssertInvalidArgument checks that q.CheckAll() reports a queue.InvalidArgument for the step at
+// the given path and returns it
+func AssertInvalidArgument(t testing.TB, q *queue.Queue, path queue.StepPath) queue.InvalidArgument {
+	t.Helper()
+	var ia queue.InvalidArgument
+	if err := checkError(q, path, &ia); err != "" {
+		t.Errorf("expecting queue.InvalidArgument at %s, but %s", path, err)
+	}
+	return ia
+}
+
+// AssertInvalidFunc checks that q.CheckAll() reports a queue.InvalidFunc for the step at
+// the given path and returns it
+func AssertInvalidFunc(t testing.TB, q *queue.Queue, path queue.StepPath) queue.InvalidFunc {
+	t.Helper()
+	var inv queue.InvalidFunc
+	if err := checkError(q, path, &inv); err != "" {
+		t.Errorf("expecting queue.InvalidFunc at %s, but %s", path, err)
+	}
+	return inv
+}
+
+// checkError sets target to the error of CheckAll() of the given type at path and
+// returns a description of the problem, if there is none
+func checkError(q *queue.Queue, path queue.StepPath, target interface{}) string {
+	err := q.CheckAll()
+	if err == nil {
+		return "the queue is valid"
+	}
+	errs, ok := err.(queue.CheckErrors)
+	if !ok {
+		return fmt.Sprintf("got %T: %v", err, err)
+	}
+
+	t := reflect.TypeOf(target).Elem()
+	var found []string
+	for _, e := range errs {
+		if e.Path != path {
+			continue
+		}
+		if reflect.TypeOf(e.Err) == t {
+			reflect.ValueOf(target).Elem().Set(reflect.ValueOf(e.Err))
+			return ""
+		}
+		found = append(found, fmt.Sprintf("%T", e.Err))
+	}
+	if len(found) > 0 {
+		return fmt.Sprintf("got %v", found)
+	}
+	return fmt.Sprintf("there is no error at this path:\n%v", errs)
+}
@@ -0,0 +1,151 @@
+package queuetest
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/go-on/queue.v2"
+)
+
+func TestSpy(t *testing.T) {
+	join := NewSpy(strings.Join)
+	fake := Fake(strconv.Atoi, 42)
+	failing := Fake(strconv.Atoi, 0, errors.New("fail"))
+
+	err := queue.New().
+		Add(join.Func(), []string{"1", "2"}, "").
+		Add(fake.Func(), queue.PIPE).
+		Run()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []SpyCall{{Args: []interface{}{[]string{"1", "2"}, ""}, Returns: []interface{}{"12"}}}
+	if !reflect.DeepEqual(join.Calls(), expected) {
+		t.Errorf("wrong calls %#v", join.Calls())
+	}
+
+	expected = []SpyCall{{Args: []interface{}{"12"}, Returns: []interface{}{42, nil}}}
+	if !reflect.DeepEqual(fake.Calls(), expected) {
+		t.Errorf("wrong calls %#v", fake.Calls())
+	}
+
+	if err := queue.Add(failing.Func(), "1").Run(); err == nil || err.Error() != "fail" {
+		t.Errorf("expecting error \"fail\", but got %v", err)
+	}
+
+	fake.Reset()
+	if len(fake.Calls()) != 0 {
+		t.Errorf("calls should be removed")
+	}
+}
+
+func TestSpyVariadic(t *testing.T) {
+	spy := NewSpy(func(prefix string, s ...string) string { return prefix + strings.Join(s, "") })
+	queue.Add(spy.Func(), "a", "b", "c").Run()
+
+	calls := spy.Calls()
+	if len(calls) != 1 || calls[0].Returns[0] != "abc" {
+		t.Errorf("wrong calls %#v", calls)
+	}
+}
+
+func TestFakePanics(t *testing.T) {
+	for i, fn := range []func(){
+		func() { Fake("no func") },
+		func() { Fake(strconv.Itoa, "a", "b") },
+		func() { Fake(strconv.Itoa, 1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("tests[%d]: expecting panic", i)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func double(i int) int { return i * 2 }
+
+func TestProbe(t *testing.T) {
+	q := queue.New().
+		AddNamed("atoi", strconv.Atoi, "2").
+		AddNamed("double", double, queue.PIPE).
+		AddNamed("itoa", strconv.Itoa, queue.PIPE)
+
+	p := Attach(q, "atoi", "double", "itoa")
+	if err := q.Run(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.AssertOrder(t, "atoi", "double", "itoa")
+	p.AssertCalledWith(t, "double", 2)
+
+	p = Attach(q, "atoi", "double", "itoa").FailAt("double", errors.New("injected"))
+	if err := q.Run(); err == nil || err.Error() != "injected" {
+		t.Errorf("expecting injected error, but got %v", err)
+	}
+	p.AssertOrder(t, "atoi", "double")
+	p.AssertNotCalled(t, "itoa")
+
+	p = Attach(q, "double").PanicAt("itoa", "boom")
+	cp := AssertCallPanic(t, q.Run(), "2")
+	if cp.ErrorMessage != "boom" || cp.Name != "itoa" {
+		t.Errorf("wrong CallPanic %#v", cp)
+	}
+	if len(p.Steps()) != 1 {
+		t.Errorf("wrong steps %#v", p.Steps())
+	}
+}
+
+func TestAssertionsFail(t *testing.T) {
+	q := queue.New().AddNamed("atoi", strconv.Atoi, "2")
+	p := Attach(q, "atoi")
+	q.Run()
+
+	tests := []func(tb testing.TB){
+		func(tb testing.TB) { p.AssertOrder(tb, "atoi", "x") },
+		func(tb testing.TB) { p.AssertCalledWith(tb, "atoi", "3") },
+		func(tb testing.TB) { p.AssertCalledWith(tb, "x") },
+		func(tb testing.TB) { p.AssertNotCalled(tb, "atoi") },
+		func(tb testing.TB) { AssertCallPanic(tb, errors.New("x"), "0") },
+		func(tb testing.TB) { AssertInvalidArgument(tb, q, "0") },
+		func(tb testing.TB) { AssertInvalidFunc(tb, queue.Add(strconv.Atoi, 1), "0") },
+	}
+
+	for i, test := range tests {
+		r := &recordingTB{TB: t}
+		test(r)
+		if !r.failed {
+			t.Errorf("tests[%d]: assertion should fail", i)
+		}
+	}
+}
+
+func TestAssertCheckErrors(t *testing.T) {
+	q := queue.New().
+		Add(strconv.Atoi, "1").
+		Add(strconv.Itoa, queue.Call(strings.ToUpper, 3)).
+		Add("no func")
+
+	ia := AssertInvalidArgument(t, q, "1/arg0")
+	if ia.Type != "func(string) string" {
+		t.Errorf("wrong InvalidArgument %#v", ia)
+	}
+	AssertInvalidFunc(t, q, "2")
+}
+
+// recordingTB records failures instead of failing the test
+type recordingTB struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) { r.failed = true }
@@ -0,0 +1,120 @@
+package queue
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+type callrace struct {
+	queues []Queuer
+
+	// if > 0, the next queue is started after delay (or if the
+	// running ones failed), otherwise all queues are started at once
+	delay time.Duration
+}
+
+// Race is a pseudo argument that runs the given queues concurrently with the piped values.
+// The returned values of the first queue that finishes without an error are
+// passed to the receiving function. The other queues are canceled via the context
+// of the run (see RunContext() and CTX).
+//
+// If all queues fail, a RaceError with all the errors is passed to the error handler
+// of the queue.
+func Race(qs ...Queuer) callrace { return callrace{queues: qs} }
+
+// Hedge works like Race but starts the queues one after another: The next queue
+// is started when delay has passed without a successful result or when
+// all running queues failed.
+func Hedge(delay time.Duration, qs ...Queuer) callrace {
+	return callrace{queues: qs, delay: delay}
+}
+
+type raceResult struct {
+	pos     int
+	returns []reflect.Value
+	err     error
+	panic   interface{}
+}
+
+// race runs the queues of r and returns the values of the first successful one
+func (q *Queue) race(ctx context.Context, r callrace, piped []reflect.Value) (returns []reflect.Value, err error) {
+	if len(r.queues) == 0 {
+		return piped, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// buffered, so that the losers never block
+	results := make(chan raceResult, len(r.queues))
+	started := 0
+
+	start := func() {
+		pos := started
+		started++
+		go func() {
+			res := raceResult{pos: pos}
+			defer func() {
+				res.panic = recover()
+				results <- res
+			}()
+			res.returns, res.err = r.queues[pos].Queue().runAndReturn(stepAt(ctx, "race", pos), piped)
+		}()
+	}
+
+	start()
+	if r.delay <= 0 {
+		for started < len(r.queues) {
+			start()
+		}
+	}
+
+	errs := make([]error, len(r.queues))
+	var hedge *time.Timer
+	defer func() {
+		if hedge != nil {
+			hedge.Stop()
+		}
+	}()
+
+	for finished := 0; finished < started; {
+		var next <-chan time.Time
+		if started < len(r.queues) {
+			if hedge == nil {
+				hedge = time.NewTimer(r.delay)
+			}
+			next = hedge.C
+		}
+
+		select {
+		case res := <-results:
+			finished++
+			if res.panic != nil {
+				// e.g. the error handler PANIC, raise it in the running goroutine
+				panic(res.panic)
+			}
+			if res.err == nil {
+				q.logDebug("[R] %d of %d won", res.pos, len(r.queues))
+				returns = res.returns
+				return
+			}
+			errs[res.pos] = res.err
+			if finished == started && started < len(r.queues) {
+				// all running queues failed, don't wait for the delay
+				hedge.Stop()
+				hedge = nil
+				start()
+			}
+		case <-next:
+			hedge = nil
+			start()
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+	}
+
+	err = RaceError{Errors: errs}
+	return
+}
@@ -0,0 +1,186 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func slowValue(ctx context.Context, d time.Duration, s string) (string, error) {
+	select {
+	case <-time.After(d):
+		return s, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func failValue(s string) (string, error) {
+	return "", errors.New(s)
+}
+
+func TestRace(t *testing.T) {
+	var got string
+	canceled := make(chan error, 1)
+
+	loser := func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return "", ctx.Err()
+	}
+
+	err := Add(
+		Set, &got, Race(
+			Add(loser, CTX),
+			Add(slowValue, CTX, time.Millisecond, "fast"),
+		),
+	).CheckAndRun()
+
+	if err != nil {
+		t.Errorf("expecting no error but got: %s", err)
+	}
+
+	if got != "fast" {
+		t.Errorf("got should be %#v, but is: %#v", "fast", got)
+	}
+
+	select {
+	case e := <-canceled:
+		if e != context.Canceled {
+			t.Errorf("loser should be canceled, but got: %v", e)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("loser was not canceled")
+	}
+}
+
+func TestRaceFirstFails(t *testing.T) {
+	var got string
+	err := Add(
+		Set, &got, Race(
+			Add(failValue, "broken"),
+			Add(slowValue, CTX, 10*time.Millisecond, "slow"),
+		),
+	).Run()
+
+	if err != nil {
+		t.Errorf("expecting no error but got: %s", err)
+	}
+
+	if got != "slow" {
+		t.Errorf("got should be %#v, but is: %#v", "slow", got)
+	}
+}
+
+func TestRaceError(t *testing.T) {
+	var handled error
+	handler := ErrHandlerFunc(func(err error) error {
+		handled = err
+		return err
+	})
+
+	err := OnError(handler).Add(
+		Value, "x",
+	).Add(
+		fmt.Sprint, Race(
+			Add(failValue, "a"),
+			Add(failValue, "b"),
+		),
+	).Run()
+
+	raceErr, ok := err.(RaceError)
+	if !ok {
+		t.Fatalf("error should be RaceError, but is %T", err)
+	}
+
+	if handled == nil {
+		t.Errorf("RaceError should be passed to the error handler")
+	}
+
+	if len(raceErr.Errors) != 2 || raceErr.Errors[0].Error() != "a" || raceErr.Errors[1].Error() != "b" {
+		t.Errorf("wrong errors: %#v", raceErr.Errors)
+	}
+
+	if !strings.Contains(err.Error(), "[1] b") {
+		t.Errorf("error message should contain the second error, but is: %#v", err.Error())
+	}
+}
+
+func TestHedge(t *testing.T) {
+	var got string
+	var started []string
+	var mx sync.Mutex
+
+	track := func(s string) string {
+		mx.Lock()
+		started = append(started, s)
+		mx.Unlock()
+		return s
+	}
+
+	err := Add(
+		Set, &got, Hedge(time.Millisecond*5,
+			Add(track, "first").Add(slowValue, CTX, time.Second, PIPE),
+			Add(track, "second").Add(slowValue, CTX, time.Millisecond, PIPE),
+			Add(track, "third"),
+		),
+	).Run()
+
+	if err != nil {
+		t.Errorf("expecting no error but got: %s", err)
+	}
+
+	if got != "second" {
+		t.Errorf("got should be %#v, but is: %#v", "second", got)
+	}
+
+	mx.Lock()
+	defer mx.Unlock()
+	if len(started) != 2 {
+		t.Errorf("only two queues should be started, but got: %#v", started)
+	}
+}
+
+func TestHedgeStartsNextOnFailure(t *testing.T) {
+	var got string
+	begin := time.Now()
+
+	err := Add(
+		Set, &got, Hedge(time.Hour,
+			Add(failValue, "broken"),
+			Add(Value, "next"),
+		),
+	).Run()
+
+	if err != nil {
+		t.Errorf("expecting no error but got: %s", err)
+	}
+
+	if got != "next" {
+		t.Errorf("got should be %#v, but is: %#v", "next", got)
+	}
+
+	if time.Since(begin) > time.Minute {
+		t.Errorf("next queue should be started without waiting for the delay")
+	}
+}
+
+func TestRunContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result = ""
+	err := Add(set, "a").RunContext(ctx)
+
+	if err != context.Canceled {
+		t.Errorf("expecting context.Canceled, but got: %v", err)
+	}
+
+	if result != "" {
+		t.Errorf("no call should be made, but result is: %#v", result)
+	}
+}
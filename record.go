@@ -0,0 +1,368 @@
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// TraceEntry is a recorded call or tee of a run (see Recorder)
+type TraceEntry struct {
+	// path of the call or tee
+	Path StepPath `json:"path"`
+
+	// name of the call or tee, if it is named
+	Name string `json:"name,omitempty"`
+
+	// type of the function
+	Func string `json:"func"`
+
+	// the resolved arguments in JSON
+	Args []json.RawMessage `json:"args"`
+
+	// the returned non error values in JSON
+	Returns []json.RawMessage `json:"returns"`
+
+	// message of the returned error, if any
+	Error string `json:"error,omitempty"`
+
+	// "Halt" or "Return", if the call stopped the queue with Halt or Return() (see Halt)
+	Halt string `json:"halt,omitempty"`
+
+	// the values passed to Return() in JSON
+	Return []json.RawMessage `json:"return,omitempty"`
+}
+
+// recordFailure records the error err returned by the call, Halt and Return() as such
+func (e *TraceEntry) recordFailure(err error) {
+	var r *returnError
+	switch {
+	case err == nil:
+		return
+	case errors.As(err, &r):
+		e.Halt, e.Return = "Return", toJSON(r.values)
+	case errors.Is(err, Halt):
+		e.Halt = "Halt"
+	default:
+		e.Error = err.Error()
+		if _, notOK := err.(NotOK); notOK {
+			e.Error = "not ok"
+		}
+	}
+}
+
+// failure returns the error that is replayed for e, nil if e has none
+func (e *TraceEntry) failure() error {
+	switch e.Halt {
+	case "Halt":
+		return Halt
+	case "Return":
+		values := make([]interface{}, len(e.Return))
+		for i, data := range e.Return {
+			json.Unmarshal(data, &values[i])
+		}
+		return Return(values...)
+	}
+	if e.Error != "" {
+		return errors.New(e.Error)
+	}
+	return nil
+}
+
+// Recorder writes every call and tee of the runs of a queue as TraceEntry in JSON lines
+// to a writer (see SetRecorder()).
+//
+// Arguments and returned values that can't be marshalled to JSON are recorded as null
+// and calls that panic are not recorded.
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder returns a Recorder that writes the trace to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// Err returns the first error writing the trace
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(e *TraceEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = r.enc.Encode(e)
+	}
+}
+
+// SetRecorder attaches the recorder r to q. If r is nil, the recorder is removed.
+//
+// Like the debugger, only the recorder of the queue that is run counts, but it
+// records the calls of nested queues as well.
+func (q *Queue) SetRecorder(r *Recorder) *Queue {
+	q.recorder = r
+	return q
+}
+
+// SetReplayer attaches the replayer r to q. If r is nil, the replayer is removed.
+//
+// Like the debugger, only the replayer of the queue that is run counts, but it
+// stubs the calls of nested queues as well.
+func (q *Queue) SetReplayer(r *Replayer) *Queue {
+	q.replayer = r
+	return q
+}
+
+// toJSON marshals every value, values that can't be marshalled become null
+func toJSON(vals []interface{}) []json.RawMessage {
+	res := make([]json.RawMessage, len(vals))
+	for i, v := range vals {
+		data, err := json.Marshal(v)
+		if err != nil {
+			data = []byte("null")
+		}
+		res[i] = data
+	}
+	return res
+}
+
+// recordStep records the call c with the failure convention f at the path of ctx, if the run has a recorder
+func recordStep(ctx context.Context, c *call, f Failure, args []interface{}, returns []reflect.Value) {
+	h, ok := ctx.Value(hooksKey{}).(runHooks)
+	if !ok || h.recorder == nil || c.feed != 0 {
+		return
+	}
+
+	e := &TraceEntry{
+		Path: h.path,
+		Name: c.name,
+		Func: c.function.Type().String(),
+		Args: toJSON(args),
+	}
+	vals := toInterfaces(returns)
+	if n := len(vals); n > 0 && reportsFailure(c.function.Type().Out(n-1), f) {
+		e.recordFailure(failed(returns[n-1]))
+		vals = vals[:n-1]
+	}
+	e.Returns = toJSON(vals)
+	h.recorder.record(e)
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Replayer runs a queue with stubbed calls that return the values and errors of a recorded
+// trace instead of calling their functions (see SetReplayer()).
+//
+// A stubbed call gets the next recorded TraceEntry with its path. The returned values are decoded
+// from JSON into the result types of the function and a recorded error is returned as error with
+// the recorded message. If the arguments of the call differ from the recorded ones, the
+// difference is reported as ReplayMismatch, but the recorded values are returned anyway.
+// A stubbed call without recorded entry returns a ReplayMismatch as error.
+//
+// Recorded errors keep their message, but not their type, so errors.Is() and errors.As()
+// don't recognize them, except for Halt and Return(). The values passed to Return() are
+// decoded from JSON into interface{}, i.e. numbers become float64.
+//
+// Recorded errors of functions that return a bool (see FailOnFalse) are replayed as false.
+// If the error type of the function can't hold a recorded error, the call returns the zero
+// values and the recorded error is handled like an error of the call.
+type Replayer struct {
+	mu         sync.Mutex
+	stubs      map[string]bool
+	entries    map[StepPath][]*TraceEntry
+	mismatches []ReplayMismatch
+}
+
+// NewReplayer reads the trace in JSON lines (see Recorder) and returns a Replayer that stubs
+// the calls and tees with the given names. Without names, every call and tee is stubbed.
+func NewReplayer(trace io.Reader, names ...string) (*Replayer, error) {
+	r := &Replayer{entries: map[StepPath][]*TraceEntry{}}
+	if len(names) > 0 {
+		r.stubs = map[string]bool{}
+		for _, name := range names {
+			r.stubs[name] = true
+		}
+	}
+
+	sc := bufio.NewScanner(trace)
+	sc.Buffer(nil, 64*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		e := &TraceEntry{}
+		if err := json.Unmarshal(sc.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("invalid trace entry in line %d: %s", line, err)
+		}
+		if r.stubbed(e.Name) {
+			r.entries[e.Path] = append(r.entries[e.Path], e)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Replayer) stubbed(name string) bool {
+	return r.stubs == nil || r.stubs[name]
+}
+
+// Mismatches returns the differences between the replayed runs and the trace,
+// including recorded entries of stubbed calls that were not replayed.
+func (r *Replayer) Mismatches() []ReplayMismatch {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := append([]ReplayMismatch(nil), r.mismatches...)
+	for _, path := range sortedPaths(r.entries) {
+		for _, e := range r.entries[path] {
+			res = append(res, ReplayMismatch{Path: e.Path, Name: e.Name, ErrorMessage: "recorded, but not called"})
+		}
+	}
+	return res
+}
+
+// Err returns the first mismatch (see Mismatches()), nil if there is none
+func (r *Replayer) Err() error {
+	if m := r.Mismatches(); len(m) > 0 {
+		return m[0]
+	}
+	return nil
+}
+
+func (r *Replayer) mismatch(path StepPath, c *call, format string, a ...interface{}) ReplayMismatch {
+	m := ReplayMismatch{Path: path, Name: c.name, ErrorMessage: fmt.Sprintf(format, a...)}
+	r.mismatches = append(r.mismatches, m)
+	return m
+}
+
+// next returns the next recorded entry for the call c at path
+func (r *Replayer) next(path StepPath, c *call) (*TraceEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := r.entries[path]
+	if len(entries) == 0 {
+		return nil, r.mismatch(path, c, "call was not recorded")
+	}
+	e := entries[0]
+	r.entries[path] = entries[1:]
+	if len(r.entries[path]) == 0 {
+		delete(r.entries, path)
+	}
+	if e.Func != c.function.Type().String() {
+		return nil, r.mismatch(path, c, "function %s was recorded as %s", c.function.Type(), e.Func)
+	}
+	return e, nil
+}
+
+func (r *Replayer) compareArgs(path StepPath, c *call, e *TraceEntry, args []interface{}) {
+	if sameJSON(toJSON(args), e.Args) {
+		return
+	}
+	got, _ := json.Marshal(toJSON(args))
+	expected, _ := json.Marshal(e.Args)
+	r.mu.Lock()
+	r.mismatch(path, c, "arguments %s were recorded as %s", got, expected)
+	r.mu.Unlock()
+}
+
+func sameJSON(a, b []json.RawMessage) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		var va, vb interface{}
+		if json.Unmarshal(a[i], &va) != nil || json.Unmarshal(b[i], &vb) != nil || !reflect.DeepEqual(va, vb) {
+			return false
+		}
+	}
+	return true
+}
+
+// replayStub returns the stub for the call c with the failure convention f at the path of ctx
+// that returns the recorded values instead of calling fn, if the run has a replayer that stubs c.
+// Errors that the stub can't return are set to failure instead.
+func replayStub(ctx context.Context, c *call, f Failure, args []interface{}, fn reflect.Value, failure *error) reflect.Value {
+	h, ok := ctx.Value(hooksKey{}).(runHooks)
+	if !ok || h.replayer == nil || c.feed != 0 || !h.replayer.stubbed(c.name) {
+		return fn
+	}
+
+	ftype := c.function.Type()
+	return reflect.MakeFunc(ftype, func([]reflect.Value) []reflect.Value {
+		e, err := h.replayer.next(h.path, c)
+		if err == nil {
+			h.replayer.compareArgs(h.path, c, e, args)
+			var returns []reflect.Value
+			returns, err = decodeReturns(ftype, f, e, failure)
+			if err == nil {
+				return returns
+			}
+			h.replayer.mu.Lock()
+			err = h.replayer.mismatch(h.path, c, "%s", err)
+			h.replayer.mu.Unlock()
+		}
+		*failure = err
+		return zeroValues(ftype)
+	})
+}
+
+func zeroValues(ftype reflect.Type) []reflect.Value {
+	returns := make([]reflect.Value, ftype.NumOut())
+	for i := range returns {
+		returns[i] = reflect.New(ftype.Out(i)).Elem()
+	}
+	return returns
+}
+
+// decodeReturns decodes the recorded values and error of e into the results of a function of
+// type ftype with the failure convention f. A recorded error that the results can't hold is set
+// to failure.
+func decodeReturns(ftype reflect.Type, f Failure, e *TraceEntry, failure *error) ([]reflect.Value, error) {
+	returns := zeroValues(ftype)
+	num := len(returns)
+	if num > 0 && reportsFailure(ftype.Out(num-1), f) {
+		num--
+		// a recorded failure of a bool is the zero value false
+		if t, err := ftype.Out(num), e.failure(); err != nil && t.Kind() != reflect.Bool {
+			if reflect.TypeOf(err).AssignableTo(t) {
+				returns[num].Set(reflect.ValueOf(err))
+			} else {
+				*failure = err
+			}
+		}
+	}
+
+	if len(e.Returns) != num {
+		return nil, fmt.Errorf("%d values were recorded, but function %s returns %d", len(e.Returns), ftype, num)
+	}
+	for i, data := range e.Returns {
+		v := reflect.New(ftype.Out(i))
+		if err := json.Unmarshal(data, v.Interface()); err != nil {
+			return nil, fmt.Errorf("can't decode recorded value %s into %s: %s", data, ftype.Out(i), err)
+		}
+		returns[i] = v.Elem()
+	}
+	return returns, nil
+}
+
+func sortedPaths(m map[StepPath][]*TraceEntry) []StepPath {
+	paths := make([]StepPath, 0, len(m))
+	for p := range m {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+	return paths
+}
@@ -0,0 +1,207 @@
+package queue
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func fetchQueue(fetch func(string) (string, error)) *Queue {
+	return New().
+		AddNamed("fetch", fetch, "/a").
+		Add(strings.ToUpper, PIPE).Tee(appendString, PIPE).
+		Add(appendString, CallNamed("fetch", fetch, "/b")).
+		AddNamed("fetch", fetch, "/missing")
+}
+
+func onlineFetch(url string) (string, error) {
+	if url == "/missing" {
+		return "", fmt.Errorf("404")
+	}
+	return "page" + url, nil
+}
+
+func offlineFetch(url string) (string, error) {
+	return "", errors.New("offline")
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	var trace bytes.Buffer
+	rec := NewRecorder(&trace)
+
+	result = ""
+	err := fetchQueue(onlineFetch).SetRecorder(rec).Run()
+	if err == nil || err.Error() != "404" || rec.Err() != nil {
+		t.Fatalf("unexpected errors: %v, %v", err, rec.Err())
+	}
+
+	lines := strings.Split(strings.TrimSpace(trace.String()), "\n")
+	expected := []string{
+		`{"path":"0","name":"fetch","func":"func(string) (string, error)","args":["/a"],"returns":["page/a"]}`,
+		`{"path":"1","func":"func(string) string","args":["page/a"],"returns":["PAGE/A"]}`,
+		`{"path":"1/tee0","func":"func(...string) error","args":["PAGE/A"],"returns":[]}`,
+		`{"path":"2/arg0","name":"fetch","func":"func(string) (string, error)","args":["/b"],"returns":["page/b"]}`,
+		`{"path":"2","func":"func(...string) error","args":["page/b"],"returns":[]}`,
+		`{"path":"3","name":"fetch","func":"func(string) (string, error)","args":["/missing"],"returns":[""],"error":"404"}`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong trace:\n%s", trace.String())
+	}
+
+	// all calls are stubbed
+	rep, err := NewReplayer(bytes.NewReader(trace.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	result = ""
+	err = fetchQueue(offlineFetch).SetReplayer(rep).Run()
+	if err == nil || err.Error() != "404" || result != "" || rep.Err() != nil {
+		t.Errorf("unexpected replay: %#v, %v, %v", result, err, rep.Err())
+	}
+
+	// only the named calls are stubbed
+	rep, _ = NewReplayer(bytes.NewReader(trace.Bytes()), "fetch")
+	result = ""
+	err = fetchQueue(offlineFetch).SetReplayer(rep).Run()
+	if err == nil || err.Error() != "404" || result != "PAGE/Apage/b" || rep.Err() != nil {
+		t.Errorf("unexpected replay: %#v, %v, %v", result, err, rep.Err())
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	trace := `{"path":"0","name":"get","func":"func(string) (string, error)","args":["x"],"returns":["a"]}
+{"path":"1","func":"func(...string) error","args":["a"],"returns":[]}
+{"path":"2","func":"func(...string) error","args":["b"],"returns":[]}
+`
+	rep, err := NewReplayer(strings.NewReader(trace))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = OnError(IGNORE).SetReplayer(rep).
+		AddNamed("get", onlineFetch, "y").
+		Add(appendString, PIPE).
+		Add(read).
+		Run()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{
+		`replay mismatch at 0 "get": arguments ["y"] were recorded as ["x"]`,
+		`replay mismatch at 2: function func() string was recorded as func(...string) error`,
+	}
+	mismatches := rep.Mismatches()
+	if len(mismatches) != len(expected) {
+		t.Fatalf("expecting %d mismatches, but got %v", len(expected), mismatches)
+	}
+	for i, m := range mismatches {
+		if m.Error() != expected[i] {
+			t.Errorf("mismatches[%d] should be %#v, but is %#v", i, expected[i], m.Error())
+		}
+	}
+
+	rep, _ = NewReplayer(strings.NewReader(trace))
+	New().SetReplayer(rep).AddNamed("get", onlineFetch, "x").Run()
+	if err := rep.Err(); err == nil || err.Error() != "replay mismatch at 1: recorded, but not called" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := NewReplayer(strings.NewReader("{\n")); err == nil {
+		t.Errorf("expecting error for invalid trace")
+	}
+}
+
+func TestReplayFailures(t *testing.T) {
+	set := func(i int) (int, numError) {
+		if i == 5 {
+			return 0, numError(5)
+		}
+		return i, 0
+	}
+	lookup := func(key string) (string, bool) { return "", false }
+	failing := func() *Queue {
+		return New().FailOn(FailOnFalse).Add(set, 3).Add(set, PIPE).Add(set, 5)
+	}
+
+	var trace bytes.Buffer
+	err := failing().SetRecorder(NewRecorder(&trace)).Run()
+	if _, ok := err.(numError); !ok {
+		t.Fatalf("expecting numError, but got %v", err)
+	}
+	err = New().FailOn(FailOnFalse).Add(lookup, "a").SetRecorder(NewRecorder(&trace)).Run()
+	if _, ok := err.(NotOK); !ok {
+		t.Fatalf("expecting NotOK, but got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(trace.String()), "\n")
+	expected := []string{
+		`{"path":"0","func":"func(int) (int, queue.numError)","args":[3],"returns":[3]}`,
+		`{"path":"1","func":"func(int) (int, queue.numError)","args":[3],"returns":[3]}`,
+		`{"path":"2","func":"func(int) (int, queue.numError)","args":[5],"returns":[0],"error":"can't set to 5"}`,
+		`{"path":"0","func":"func(string) (string, bool)","args":["a"],"returns":[""],"error":"not ok"}`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong trace:\n%s", trace.String())
+	}
+
+	// the recorded error can't be a numError
+	rep, _ := NewReplayer(strings.NewReader(strings.Join(expected[:3], "\n")))
+	err = failing().SetReplayer(rep).Run()
+	if err == nil || err.Error() != "can't set to 5" || rep.Err() != nil {
+		t.Errorf("unexpected replay: %v, %v", err, rep.Err())
+	}
+
+	rep, _ = NewReplayer(strings.NewReader(expected[3]))
+	err = New().FailOn(FailOnFalse).Add(lookup, "a").SetReplayer(rep).Run()
+	if _, ok := err.(NotOK); !ok || rep.Err() != nil {
+		t.Errorf("unexpected replay: %v, %v", err, rep.Err())
+	}
+
+	// calls without recorded entry return the mismatch instead of panicking
+	rep, _ = NewReplayer(strings.NewReader(""))
+	err = failing().SetReplayer(rep).Run()
+	if m, ok := err.(ReplayMismatch); !ok || m.Path != "0" {
+		t.Errorf("expecting ReplayMismatch at 0, but got %#v", err)
+	}
+}
+
+func TestRecordAndReplayHalt(t *testing.T) {
+	cached := func(key string) (string, error) {
+		if key == "a" {
+			return "cached", Halt
+		}
+		return "", Return("returned")
+	}
+	var got []string
+	halting := func(fn func(string) (string, error)) *Queue {
+		return New().
+			Add(func(s string) { got = append(got, s) }, Run(AddNamed("cached", fn, "a").Add(Value, "not reached"))).
+			Add(func(s string) { got = append(got, s) }, Run(AddNamed("cached", fn, "b").Add(Value, "not reached")))
+	}
+
+	var trace bytes.Buffer
+	if err := halting(cached).SetRecorder(NewRecorder(&trace)).Run(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(trace.String()), "\n")
+	expected := []string{
+		`{"path":"0/arg0/run0/0","name":"cached","func":"func(string) (string, error)","args":["a"],"returns":["cached"],"halt":"Halt"}`,
+		`{"path":"0","func":"func(string)","args":["cached"],"returns":[]}`,
+		`{"path":"1/arg0/run0/0","name":"cached","func":"func(string) (string, error)","args":["b"],"returns":[""],"halt":"Return","return":["returned"]}`,
+		`{"path":"1","func":"func(string)","args":["returned"],"returns":[]}`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong trace:\n%s", trace.String())
+	}
+
+	rep, _ := NewReplayer(bytes.NewReader(trace.Bytes()), "cached")
+	got = nil
+	err := halting(offlineFetch).SetReplayer(rep).Run()
+	if err != nil || rep.Err() != nil || strings.Join(got, ",") != "cached,returned" {
+		t.Errorf("unexpected replay: %#v, %v, %v", got, err, rep.Err())
+	}
+}
@@ -0,0 +1,112 @@
+package queue
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Registry maps names to functions and error handlers, so that queues can be
+// defined declaratively (see QueueDef) and loaded without recompiling.
+//
+// The error handlers STOP, IGNORE and PANIC are registered by default.
+type Registry struct {
+	funcs    map[string]reflect.Value
+	handlers map[string]ErrHandler
+}
+
+// NewRegistry returns a new Registry with the default error handlers
+func NewRegistry() *Registry {
+	r := &Registry{
+		funcs:    map[string]reflect.Value{},
+		handlers: map[string]ErrHandler{},
+	}
+	r.RegisterErrHandler("STOP", STOP)
+	r.RegisterErrHandler("IGNORE", IGNORE)
+	r.RegisterErrHandler("PANIC", PANIC)
+	return r
+}
+
+// Register registers the function under the given name and may be chained.
+// It panics if function is no func.
+//
+// Method values (e.g. p.SetAge) may be registered, but can't be told apart by Marshal(),
+// if more than one method value of the same method is registered.
+func (r *Registry) Register(name string, function interface{}) *Registry {
+	fn := reflect.ValueOf(function)
+	if fn.Kind() != reflect.Func {
+		panic(fmt.Sprintf("can't register %#v: %T is no func", name, function))
+	}
+	r.funcs[name] = fn
+	return r
+}
+
+// RegisterErrHandler registers the error handler under the given name and may be chained
+func (r *Registry) RegisterErrHandler(name string, handler ErrHandler) *Registry {
+	r.handlers[name] = handler
+	return r
+}
+
+// Func returns the function registered under the given name, nil if there is none
+func (r *Registry) Func(name string) interface{} {
+	fn, ok := r.funcs[name]
+	if !ok {
+		return nil
+	}
+	return fn.Interface()
+}
+
+// ErrHandler returns the error handler registered under the given name, nil if there is none
+func (r *Registry) ErrHandler(name string) ErrHandler {
+	return r.handlers[name]
+}
+
+// funcName returns the name of the registered function fn
+func (r *Registry) funcName(fn reflect.Value) (string, bool) {
+	for _, name := range r.sortedFuncs() {
+		reg := r.funcs[name]
+		if reg.Type() == fn.Type() && reg.Pointer() == fn.Pointer() {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (r *Registry) sortedFuncs() []string {
+	names := make([]string, 0, len(r.funcs))
+	for name := range r.funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// errHandlerName returns the name of the registered error handler h
+func (r *Registry) errHandlerName(h ErrHandler) (string, bool) {
+	names := make([]string, 0, len(r.handlers))
+	for name := range r.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if sameErrHandler(r.handlers[name], h) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// sameErrHandler checks, if a and b are the same error handler, even if they are funcs
+func sameErrHandler(a, b ErrHandler) bool {
+	ta := reflect.TypeOf(a)
+	if ta != reflect.TypeOf(b) {
+		return false
+	}
+	if ta.Kind() == reflect.Func {
+		return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+	}
+	if ta.Comparable() {
+		return a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -1,6 +1,9 @@
 package queue
 
-import "reflect"
+import (
+	"context"
+	"reflect"
+)
 
 // Run runs the function queue.
 //
@@ -17,6 +20,9 @@ import "reflect"
 //
 // The default ErrHandler is STOP, which will stop the run on the first error.
 //
+// If a function or tee returns Halt or Return(), the queue is stopped without
+// an error and without calling the ErrHandler (see Halt).
+//
 // If there are any errors with the given function types and arguments, the errors
 // will no be very descriptive. In this cases use CheckAndRun() to see if there are any
 // errors in the function or argument types or use LogDebugTo to get detailed debugging
@@ -24,61 +30,137 @@ import "reflect"
 //
 // Since no arguments are saved inside the queue, a queue might be run multiple times.
 func (q *Queue) Run() (err error) {
-	return q.run(nil)
+	return q.run(context.Background(), nil)
+}
+
+// RunContext runs the queue like Run(), but stops the run with the error of
+// the given context as soon as it is canceled.
+//
+// The context is checked before each call and passed to every function that
+// gets the pseudo argument CTX.
+func (q *Queue) RunContext(ctx context.Context) (err error) {
+	return q.run(ctx, nil)
+}
+
+// RunWithInput runs the queue like RunContext(), but pipes the given input values to the
+// first call, like a parent queue pipes its values to a queue passed via Sub().
+// It returns the final piped values of the queue.
+func (q *Queue) RunWithInput(ctx context.Context, input ...interface{}) ([]interface{}, error) {
+	returns, err := q.runAndReturn(ctx, toValues(input))
+	if err != nil {
+		return nil, err
+	}
+	return toInterfaces(returns), nil
 }
 
 // run with given start values and return the last return values
-func (q *Queue) runAndReturn(vals []reflect.Value) (returns []reflect.Value, err error) {
-	errHandler := q.errHandler
-	// default error handler is STOP
-	if errHandler == nil {
-		errHandler = STOP
+func (q *Queue) runAndReturn(ctx context.Context, vals []reflect.Value) (returns []reflect.Value, err error) {
+	return q.runAround(ctx, vals)
+}
+
+// runCalls runs the calls of q with the given start values and returns the last return values
+func (q *Queue) runCalls(ctx context.Context, vals []reflect.Value) (returns []reflect.Value, err error) {
+	ctx, err = q.enterDepth(ctx)
+	if err != nil {
+		return
+	}
+	ctx = q.enterHooks(ctx)
+	ctx = q.enterConverter(ctx)
+	ctx, closer := q.enterAutoClose(ctx)
+	errHandler := q.errHandlerFor(ctx)
+	ctx = context.WithValue(ctx, errHandlerKey{}, errHandler)
+	if closer != nil {
+		defer func() {
+			if closeErr := q.handleCloseErrors(errHandler, closer.closeAll()); err == nil {
+				err = closeErr
+			}
+		}()
+	}
+
+	// piped values that are not piped on are closed, if the run closes values automatically,
+	// apart from the values passed to the queue, which are closed by the queue they come from
+	input := vals
+	ac := runAutoCloser(ctx)
+	pipeOn := func(prev []reflect.Value) error {
+		ac.track(vals, input)
+		return q.handleCloseErrors(errHandler, ac.release(prev, concat(vals, input)))
 	}
 
 	for i, fn := range q.calls {
+		// a canceled or aborted run or a run that exceeded the max depth is stopped, regardless of the error handler
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if err = depthExceeded(ctx); err != nil {
+			return
+		}
+		if err = debugAborted(ctx); err != nil {
+			return
+		}
+
 		if fn.function.Type() == queuersType {
-			for _, sub := range fn.function.Interface().([]Queuer) {
-				vals, err = sub.Queue().runAndReturn(vals)
+			for k, sub := range fn.function.Interface().([]Queuer) {
+				prev := vals
+				vals, err = sub.Queue().runAndReturn(stepAt(stepAt(ctx, "", i), "sub", k), vals)
+				if ab := debugAborted(ctx); ab != nil {
+					return nil, ab
+				}
 				if err != nil {
 					err2 := errHandler.HandleError(err)
 					q.logDebug("[E] %T(%#v) => %#v", errHandler, err, err2)
-					if err2 != nil {
-						err = err2
+					err = err2
+					if err != nil {
 						return
 					}
 				}
+				if err = pipeOn(prev); err != nil {
+					return
+				}
+			}
+		} else {
+			prev := vals
+			vals, err = q.pipeFn(stepAt(ctx, "", i), fn, i, vals)
+			// an aborted run is stopped, regardless of the error handlers and tees
+			if ab := debugAborted(ctx); ab != nil {
+				return nil, ab
+			}
+			if returns, halted := isHalt(err, vals); halted {
+				q.logDebug("[H] halted at %d", i)
+				return returns, nil
+			}
+			if err != nil {
+				err = q.handleError(fn, errHandler, err, "E")
+			}
+			if err != nil {
+				return
+			}
+			if err = pipeOn(prev); err != nil {
+				return
 			}
-			returns = vals
-			continue
-		}
-
-		vals, err = q.pipeFn(fn, i, vals)
-		if err != nil {
-			err2 := errHandler.HandleError(err)
-			q.logDebug("[E] %T(%#v) => %#v", errHandler, err, err2)
-			err = err2
-		}
-		if err != nil {
-			return
 		}
 
-		err = q.runTees(i, vals)
-		if err != nil {
-			err2 := errHandler.HandleError(err)
-			q.logDebug("[ET] %T(%#v) => %#v", errHandler, err, err2)
-			err = err2
+		err = q.runTees(ctx, i, vals, errHandler)
+		if returns, halted := isHalt(err, vals); halted {
+			q.logDebug("[H] halted by tee at %d", i)
+			return returns, nil
 		}
 
 		if err != nil {
 			return
 		}
 	}
+	if err = depthExceeded(ctx); err != nil {
+		return
+	}
+	if err = debugAborted(ctx); err != nil {
+		return
+	}
 	returns = vals
 	return
 }
 
 // run with given start values
-func (q *Queue) run(vals []reflect.Value) (err error) {
-	_, err = q.runAndReturn(vals)
+func (q *Queue) run(ctx context.Context, vals []reflect.Value) (err error) {
+	_, err = q.runAndReturn(ctx, vals)
 	return
 }
@@ -2,7 +2,10 @@ package queue
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"reflect"
+	"strconv"
 	"testing"
 )
 
@@ -80,3 +83,18 @@ func TestInterface(t *testing.T) {
 	}
 
 }
+
+func TestRunWithInput(t *testing.T) {
+	vals, err := New().Add(addIntsToString, PIPE).RunWithInput(context.Background(), "a", 2, 3)
+	if err != nil {
+		t.Fatalf("expecting no error, but got: %s", err.Error())
+	}
+	if !reflect.DeepEqual(vals, []interface{}{"a [2 3]"}) {
+		t.Errorf("wrong result: expected [\"a [2 3]\"], got %#v", vals)
+	}
+
+	_, err = New().Add(strconv.Atoi, PIPE).RunWithInput(context.Background(), "x")
+	if err == nil {
+		t.Errorf("expecting error, got nil")
+	}
+}
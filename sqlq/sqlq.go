@@ -0,0 +1,148 @@
+// Package sqlq runs queues of gopkg.in/go-on/queue.v2 in database/sql transactions:
+//
+//   - Tx runs queues in a transaction that is committed, if they succeed, and rolled back,
+//     if the error handler stops the run or a call panics
+//   - Savepoint runs nested queues in a savepoint of the transaction
+//   - TX passes the transaction of the run to calls that take a *sql.Tx
+//
+// E.g.
+//
+//	q := sqlq.Tx(db, nil,
+//		queue.Add(insertOrder, sqlq.TX(), order).
+//			Add(insertItems, sqlq.TX(), queue.PIPE, items).
+//			Sub(sqlq.Savepoint(queue.Add(updateStats, sqlq.TX(), order))),
+//	)
+//	err := q.Run()
+//
+// The transaction is never injected automatically: a call gets it only via TX() (or
+// FromContext()), even if its parameter is a *sql.Tx. A *sql.Tx that is passed otherwise,
+// e.g. piped or as literal argument, is a different transaction that Tx() neither commits
+// nor rolls back, which Check() does not detect.
+package sqlq
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"gopkg.in/go-on/queue.v2"
+)
+
+// ErrNoTx is returned, if the transaction of a run is needed outside of Tx
+var ErrNoTx = errors.New("sqlq: the run has no transaction")
+
+type txKey struct{}
+
+// txState is the transaction of a run
+type txState struct {
+	tx *sql.Tx
+
+	// number of savepoints, for their names
+	savepoints int64
+}
+
+// Tx returns a queue that runs the given queues like Sub() in a transaction of db, that is
+// started with the given options.
+//
+// The transaction is committed, if the run of the queues succeeds. It is rolled back, if the run
+// fails, i.e. the error handler stops the run, or a call panics, even if the CallPanic is handled by
+// an error handler. Then the error of the run or the CallPanic is returned. If the transaction
+// can't be committed or rolled back, that error is returned as well.
+//
+// The errors of the given queues are handled by the error handler of the returned queue,
+// which is STOP by default.
+func Tx(db *sql.DB, opts *sql.TxOptions, qs ...queue.Queuer) *queue.Queue {
+	return queue.New().Sub(qs...).Around(func(ctx context.Context, run func(context.Context) (error, error)) error {
+		tx, err := db.BeginTx(ctx, opts)
+		if err != nil {
+			return err
+		}
+
+		done := false
+		defer func() {
+			// the run panicked, e.g. with the error handler PANIC
+			if !done {
+				tx.Rollback()
+			}
+		}()
+
+		panicked, err := run(context.WithValue(ctx, txKey{}, &txState{tx: tx}))
+		done = true
+		if err == nil {
+			err = panicked
+		}
+		if err != nil {
+			return failed(err, "rollback", tx.Rollback())
+		}
+		return tx.Commit()
+	})
+}
+
+// Savepoint returns a queue that runs the given queues like Sub() in a savepoint of the
+// transaction of the run. It must be nested in a queue returned by Tx(), otherwise
+// the run fails with ErrNoTx.
+//
+// The savepoint is released, if the run of the queues succeeds. Like a transaction, it
+// is rolled back, if the run fails or a call panics. Then the error of the run or the CallPanic
+// is returned and the error handler of the parent queue decides, if the transaction goes on
+// without the changes of the savepoint.
+func Savepoint(qs ...queue.Queuer) *queue.Queue {
+	return queue.New().Sub(qs...).Around(func(ctx context.Context, run func(context.Context) (error, error)) error {
+		s, ok := ctx.Value(txKey{}).(*txState)
+		if !ok {
+			return ErrNoTx
+		}
+		name := fmt.Sprintf("sqlq_%d", atomic.AddInt64(&s.savepoints, 1))
+		if _, err := s.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+			return err
+		}
+
+		done := false
+		defer func() {
+			if !done {
+				s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			}
+		}()
+
+		panicked, err := run(ctx)
+		done = true
+		if err == nil {
+			err = panicked
+		}
+		if err != nil {
+			_, rbErr := s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			return failed(err, "rollback to savepoint", rbErr)
+		}
+		_, err = s.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+		return err
+	})
+}
+
+// failed returns err, with the error of the given action, if it failed as well
+func failed(err error, action string, actionErr error) error {
+	if actionErr == nil {
+		return err
+	}
+	return fmt.Errorf("%w (%s failed: %v)", err, action, actionErr)
+}
+
+// FromContext returns the transaction of the run of the given context,
+// ErrNoTx if it is not run inside Tx().
+func FromContext(ctx context.Context) (*sql.Tx, error) {
+	s, ok := ctx.Value(txKey{}).(*txState)
+	if !ok {
+		return nil, ErrNoTx
+	}
+	return s.tx, nil
+}
+
+// TX returns a pseudo argument that is replaced by the transaction of the run, like
+// queue.Call(FromContext, queue.CTX). Outside of Tx(), the call that gets it fails with ErrNoTx.
+//
+// Every *sql.Tx parameter that should get the transaction of Tx() needs TX() as argument,
+// parameters of type *sql.Tx are not filled in otherwise.
+func TX() interface{} {
+	return queue.Call(FromContext, queue.CTX)
+}
@@ -0,0 +1,176 @@
+package sqlq
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/go-on/queue.v2"
+)
+
+// fakeDB is a database/sql driver that logs the statements and transactions
+// and fails every statement that starts with FAIL
+type fakeDB struct {
+	mu  sync.Mutex
+	log []string
+}
+
+var errFail = errors.New("statement failed")
+
+func (f *fakeDB) record(s string) {
+	f.mu.Lock()
+	f.log = append(f.log, s)
+	f.mu.Unlock()
+}
+
+func (f *fakeDB) Log() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.log...)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return f }
+func (f *fakeDB) Open(string) (driver.Conn, error)             { return fakeConn{f}, nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c fakeConn) Close() error                        { return nil }
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	c.db.record("BEGIN")
+	return fakeTx{c.db}, nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.db.record(query)
+	if strings.HasPrefix(query, "FAIL") {
+		return nil, errFail
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct{ db *fakeDB }
+
+func (t fakeTx) Commit() error   { t.db.record("COMMIT"); return nil }
+func (t fakeTx) Rollback() error { t.db.record("ROLLBACK"); return nil }
+
+func newDB() (*sql.DB, *fakeDB) {
+	f := &fakeDB{}
+	return sql.OpenDB(f), f
+}
+
+func exec(tx *sql.Tx, query string) error {
+	_, err := tx.Exec(query)
+	return err
+}
+
+func TestTx(t *testing.T) {
+	panics := func(*sql.Tx) { panic("oops") }
+
+	tests := []struct {
+		q   func(db *sql.DB) *queue.Queue
+		err string
+		log []string
+	}{
+		{
+			func(db *sql.DB) *queue.Queue {
+				return Tx(db, nil, queue.Add(exec, TX(), "INSERT 1").Add(exec, TX(), "INSERT 2"))
+			},
+			"", []string{"BEGIN", "INSERT 1", "INSERT 2", "COMMIT"},
+		},
+		{
+			func(db *sql.DB) *queue.Queue {
+				return Tx(db, nil, queue.Add(exec, TX(), "INSERT 1").Add(exec, TX(), "FAIL 2").Add(exec, TX(), "INSERT 3"))
+			},
+			errFail.Error(), []string{"BEGIN", "INSERT 1", "FAIL 2", "ROLLBACK"},
+		},
+		// errors that are handled do not roll back
+		{
+			func(db *sql.DB) *queue.Queue {
+				return Tx(db, nil, queue.Add(exec, TX(), "FAIL 1").Add(exec, TX(), "INSERT 2").OnError(queue.IGNORE))
+			},
+			"", []string{"BEGIN", "FAIL 1", "INSERT 2", "COMMIT"},
+		},
+		// panics roll back, even if they are handled
+		{
+			func(db *sql.DB) *queue.Queue {
+				return Tx(db, nil, queue.Add(panics, TX()).Add(exec, TX(), "INSERT 2").OnError(queue.IGNORE))
+			},
+			"panicked", []string{"BEGIN", "INSERT 2", "ROLLBACK"},
+		},
+		// savepoints
+		{
+			func(db *sql.DB) *queue.Queue {
+				return Tx(db, nil, queue.Add(exec, TX(), "INSERT 1").
+					Sub(Savepoint(queue.Add(exec, TX(), "INSERT 2"))).
+					Sub(Savepoint(queue.Add(exec, TX(), "FAIL 3"))).
+					Sub(Savepoint(queue.Add(panics, TX()))).
+					Add(exec, TX(), "INSERT 4").
+					OnError(queue.IGNORE))
+			},
+			"", []string{"BEGIN", "INSERT 1",
+				"SAVEPOINT sqlq_1", "INSERT 2", "RELEASE SAVEPOINT sqlq_1",
+				"SAVEPOINT sqlq_2", "FAIL 3", "ROLLBACK TO SAVEPOINT sqlq_2",
+				"SAVEPOINT sqlq_3", "ROLLBACK TO SAVEPOINT sqlq_3",
+				"INSERT 4", "COMMIT"},
+		},
+		{
+			func(db *sql.DB) *queue.Queue {
+				return Tx(db, nil, queue.Add(exec, TX(), "INSERT 1").Sub(Savepoint(queue.Add(exec, TX(), "FAIL 2"))))
+			},
+			errFail.Error(), []string{"BEGIN", "INSERT 1", "SAVEPOINT sqlq_1", "FAIL 2", "ROLLBACK TO SAVEPOINT sqlq_1", "ROLLBACK"},
+		},
+	}
+
+	for i, test := range tests {
+		db, f := newDB()
+		q := test.q(db)
+		if err := q.Check(); err != nil {
+			t.Errorf("tests[%d]: Check should accept, but got %s", i, err)
+		}
+		err := q.Run()
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("tests[%d]: unexpected error %s", i, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("tests[%d]: expected error %#v, but got %v", i, test.err, err)
+		}
+		if log := f.Log(); !reflect.DeepEqual(log, test.log) {
+			t.Errorf("tests[%d]: expected %#v, but got %#v", i, test.log, log)
+		}
+	}
+}
+
+func TestTxPanic(t *testing.T) {
+	db, f := newDB()
+	q := Tx(db, nil, queue.Add(exec, TX(), "FAIL 1").OnError(queue.PANIC))
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("run should panic")
+			}
+		}()
+		q.Run()
+	}()
+
+	if log := f.Log(); !reflect.DeepEqual(log, []string{"BEGIN", "FAIL 1", "ROLLBACK"}) {
+		t.Errorf("transaction should be rolled back, but got %#v", log)
+	}
+}
+
+func TestNoTx(t *testing.T) {
+	if err := queue.Add(exec, TX(), "INSERT 1").Run(); err != ErrNoTx {
+		t.Errorf("expected ErrNoTx, but got %v", err)
+	}
+	if err := Savepoint(queue.Add(func() {})).Run(); err != ErrNoTx {
+		t.Errorf("expected ErrNoTx, but got %v", err)
+	}
+}
@@ -0,0 +1,369 @@
+package queue
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ArgKind is the kind of an argument of a call
+type ArgKind int
+
+const (
+	// ArgLiteral is a value that is passed as is
+	ArgLiteral ArgKind = iota
+	// ArgPipe is the pseudo argument PIPE
+	ArgPipe
+	// ArgContext is the pseudo argument CTX
+	ArgContext
+	// ArgCall is a call passed via Call() or CallNamed()
+	ArgCall
+	// ArgRun are queues passed via Run()
+	ArgRun
+	// ArgFallback are queues passed via Fallback()
+	ArgFallback
+	// ArgRace are queues passed via Race() or Hedge()
+	ArgRace
+)
+
+var argKindNames = [...]string{"literal", "PIPE", "CTX", "Call", "Run", "Fallback", "Race"}
+
+func (a ArgKind) String() string {
+	if a < 0 || int(a) >= len(argKindNames) {
+		return fmt.Sprintf("ArgKind(%d)", int(a))
+	}
+	return argKindNames[a]
+}
+
+// StepKind is the kind of a step of a queue
+type StepKind int
+
+const (
+	// StepCall is a function added via Add(), AddNamed() etc.
+	StepCall StepKind = iota
+	// StepSub are queues added via Sub()
+	StepSub
+	// StepTee is a function added via Tee(), TeeNamed() etc.
+	StepTee
+	// StepTeeRun are queues added via TeeAndRun() or TeeAndCheckAndRun()
+	StepTeeRun
+	// StepTeeFallback are queues added via TeeAndFallback() or TeeAndCheckAndFallback()
+	StepTeeFallback
+)
+
+var stepKindNames = [...]string{"call", "Sub", "Tee", "TeeAndRun", "TeeAndFallback"}
+
+func (s StepKind) String() string {
+	if s < 0 || int(s) >= len(stepKindNames) {
+		return fmt.Sprintf("StepKind(%d)", int(s))
+	}
+	return stepKindNames[s]
+}
+
+// ArgDescriptor describes an argument of a call
+type ArgDescriptor struct {
+	Kind ArgKind
+
+	// the value of an ArgLiteral
+	Value interface{}
+
+	// the call of an ArgCall
+	Call *StepDescriptor
+
+	// the queues of an ArgRun, ArgFallback or ArgRace
+	Queues []*Queue
+}
+
+// StepDescriptor is a read only description of a call or tee in a queue
+type StepDescriptor struct {
+	Kind StepKind
+
+	// position of the call in the queue, tees have the position of the call they are piped from
+	Position int
+
+	// name of the call, if it is named
+	Name string
+
+	// type of the function, nil for StepSub
+	Type reflect.Type
+
+	Args []ArgDescriptor
+
+	// tees that get the values returned by the call
+	Tees []StepDescriptor
+
+	// queues of StepSub, StepTeeRun or StepTeeFallback
+	Queues []*Queue
+
+	// true, if the call has its own error handler (see OnErrorAt())
+	HasErrHandler bool
+}
+
+// TypeString returns the type signature of the function or an empty string
+func (s StepDescriptor) TypeString() string {
+	if s.Type == nil {
+		return ""
+	}
+	return s.Type.String()
+}
+
+func toQueues(qs []Queuer) []*Queue {
+	res := make([]*Queue, len(qs))
+	for i, qe := range qs {
+		res[i] = qe.Queue()
+	}
+	return res
+}
+
+// describe returns the descriptor of c
+func (c *call) describe(q *Queue, kind StepKind, pos int) StepDescriptor {
+	d := StepDescriptor{
+		Kind:          kind,
+		Position:      pos,
+		Name:          c.name,
+		HasErrHandler: q.stepErrHandler(c) != nil,
+	}
+
+	if c.function.Type() == queuersType {
+		d.Kind = StepSub
+		d.Queues = toQueues(c.function.Interface().([]Queuer))
+		return d
+	}
+
+	d.Type = c.function.Type()
+
+	switch c.feed {
+	case feedRun, feedCheckRun:
+		d.Kind = StepTeeRun
+		d.Queues = toQueues(c.feeded)
+		return d
+	case feedFallback, feedCheckFallback:
+		d.Kind = StepTeeFallback
+		d.Queues = toQueues(c.feeded)
+		return d
+	}
+
+	for _, arg := range c.arguments {
+		var ad ArgDescriptor
+		switch a := arg.(type) {
+		case pipe:
+			ad.Kind = ArgPipe
+		case ctxArg:
+			ad.Kind = ArgContext
+		case *call:
+			ad.Kind = ArgCall
+			nested := a.describe(q, StepCall, pos)
+			ad.Call = &nested
+		case callrun:
+			ad.Kind = ArgRun
+			ad.Queues = toQueues(a)
+		case callfallback:
+			ad.Kind = ArgFallback
+			ad.Queues = toQueues(a)
+		case callrace:
+			ad.Kind = ArgRace
+			ad.Queues = toQueues(a.queues)
+		default:
+			ad.Kind = ArgLiteral
+			ad.Value = a
+		}
+		d.Args = append(d.Args, ad)
+	}
+	return d
+}
+
+// Steps returns descriptions of the calls of the queue in the order they are run.
+// Nested queues are not described, but returned inside the descriptors.
+func (q *Queue) Steps() []StepDescriptor {
+	steps := make([]StepDescriptor, len(q.calls))
+	for i, c := range q.calls {
+		steps[i] = c.describe(q, StepCall, i)
+		steps[i].Tees = q.Tees(i)
+	}
+	return steps
+}
+
+// Tees returns descriptions of the tees that get the values returned by the call at position pos
+func (q *Queue) Tees(pos int) []StepDescriptor {
+	var tees []StepDescriptor
+	for _, tee := range q.tees[pos] {
+		tees = append(tees, tee.describe(q, StepTee, pos))
+	}
+	return tees
+}
+
+// find returns the position of the first call or tee with the given name.
+// For calls tee is -1.
+func (q *Queue) find(name string) (pos int, tee int, err error) {
+	for i, c := range q.calls {
+		if c.name == name {
+			return i, -1, nil
+		}
+		for j, t := range q.tees[i] {
+			if t.name == name {
+				return i, j, nil
+			}
+		}
+	}
+	return -1, -1, StepNotFound{name}
+}
+
+// InsertBefore inserts the given function and arguments as a new call before the first call or
+// tee with the given name (see Add() and Tee()). The new call is not named.
+//
+// If there is no call or tee with the given name, StepNotFound is returned.
+func (q *Queue) InsertBefore(name string, function interface{}, arguments ...interface{}) error {
+	pos, tee, err := q.find(name)
+	if err != nil {
+		return err
+	}
+	c := &call{function: reflect.ValueOf(function), arguments: arguments}
+
+	if tee >= 0 {
+		q.tees[pos] = append(q.tees[pos][:tee], append([]*call{c}, q.tees[pos][tee:]...)...)
+		return nil
+	}
+
+	q.calls = append(q.calls[:pos], append([]*call{c}, q.calls[pos:]...)...)
+	tees := map[int][]*call{}
+	for i, t := range q.tees {
+		if i >= pos {
+			i++
+		}
+		tees[i] = t
+	}
+	q.tees = tees
+	return nil
+}
+
+// Replace replaces the function and arguments of the first call or tee with the given name.
+// The name, the tees and the error handler of the call are kept.
+//
+// If there is no call or tee with the given name, StepNotFound is returned.
+func (q *Queue) Replace(name string, function interface{}, arguments ...interface{}) error {
+	pos, tee, err := q.find(name)
+	if err != nil {
+		return err
+	}
+	c := q.calls[pos]
+	if tee >= 0 {
+		c = q.tees[pos][tee]
+	}
+	c.function = reflect.ValueOf(function)
+	c.arguments = arguments
+	c.feeded = nil
+	c.feed = 0
+	return nil
+}
+
+// Remove removes the first call or tee with the given name. The tees of a removed call are
+// removed as well.
+//
+// If there is no call or tee with the given name, StepNotFound is returned.
+func (q *Queue) Remove(name string) error {
+	pos, tee, err := q.find(name)
+	if err != nil {
+		return err
+	}
+
+	if tee >= 0 {
+		q.tees[pos] = append(q.tees[pos][:tee], q.tees[pos][tee+1:]...)
+		return nil
+	}
+
+	q.calls = append(q.calls[:pos], q.calls[pos+1:]...)
+	tees := map[int][]*call{}
+	for i, t := range q.tees {
+		switch {
+		case i == pos:
+			continue
+		case i > pos:
+			i--
+		}
+		tees[i] = t
+	}
+	q.tees = tees
+	return nil
+}
+
+// Clone returns a deep copy of the queue, so that the calls of the copy may be changed
+// without affecting the original. Nested queues are cloned as well, the functions and
+// literal arguments are shared. A clone of a queue that is nested in itself is nested in itself.
+func (q *Queue) Clone() *Queue {
+	return q.clone(map[*Queue]*Queue{})
+}
+
+// clone clones q, cloned are the clones of the queues q is nested in
+func (q *Queue) clone(cloned map[*Queue]*Queue) *Queue {
+	if cl, ok := cloned[q]; ok {
+		return cl
+	}
+	cl := *q
+	cloned[q] = &cl
+	defer delete(cloned, q)
+	cl.calls = cloneCalls(q.calls, cloned)
+	cl.tees = map[int][]*call{}
+	for i, tees := range q.tees {
+		cl.tees[i] = cloneCalls(tees, cloned)
+	}
+	cl.subs = map[int][]Queuer{}
+	for i, subs := range q.subs {
+		cl.subs[i] = cloneQueuers(subs, cloned)
+	}
+	if q.errHandlersAt != nil {
+		cl.errHandlersAt = map[string]ErrHandler{}
+		for name, h := range q.errHandlersAt {
+			cl.errHandlersAt[name] = h
+		}
+	}
+	if q.failuresAt != nil {
+		cl.failuresAt = map[string]Failure{}
+		for name, f := range q.failuresAt {
+			cl.failuresAt[name] = f
+		}
+	}
+	return &cl
+}
+
+func cloneQueuers(qs []Queuer, cloned map[*Queue]*Queue) []Queuer {
+	if qs == nil {
+		return nil
+	}
+	res := make([]Queuer, len(qs))
+	for i, qe := range qs {
+		res[i] = qe.Queue().clone(cloned)
+	}
+	return res
+}
+
+func cloneCalls(calls []*call, cloned map[*Queue]*Queue) []*call {
+	res := make([]*call, len(calls))
+	for i, c := range calls {
+		res[i] = c.clone(cloned)
+	}
+	return res
+}
+
+func (c *call) clone(cloned map[*Queue]*Queue) *call {
+	cl := *c
+	if c.function.Type() == queuersType {
+		cl.function = reflect.ValueOf(cloneQueuers(c.function.Interface().([]Queuer), cloned))
+		return &cl
+	}
+	cl.feeded = cloneQueuers(c.feeded, cloned)
+	cl.arguments = make([]interface{}, len(c.arguments))
+	for i, arg := range c.arguments {
+		switch a := arg.(type) {
+		case *call:
+			cl.arguments[i] = a.clone(cloned)
+		case callrun:
+			cl.arguments[i] = callrun(cloneQueuers(a, cloned))
+		case callfallback:
+			cl.arguments[i] = callfallback(cloneQueuers(a, cloned))
+		case callrace:
+			cl.arguments[i] = callrace{queues: cloneQueuers(a.queues, cloned), delay: a.delay}
+		default:
+			cl.arguments[i] = arg
+		}
+	}
+	return &cl
+}
@@ -0,0 +1,138 @@
+package queue
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestSteps(t *testing.T) {
+	sub := Add(appendString, PIPE)
+	q := AddNamed("set", set, "9").
+		Add(read).
+		TeeNamed("append", appendString, PIPE).
+		TeeAndFallback(sub).
+		Add(strconv.Atoi, Call(read)).
+		Sub(sub).
+		Add(appendString, Run(sub), Fallback(sub, sub), Race(sub), CTX)
+
+	steps := q.Steps()
+
+	if len(steps) != 5 {
+		t.Fatalf("expecting 5 steps, got %d", len(steps))
+	}
+
+	if steps[0].Name != "set" || steps[0].TypeString() != "func(string) error" {
+		t.Errorf("wrong first step: %#v", steps[0])
+	}
+
+	if len(steps[0].Args) != 1 || steps[0].Args[0].Kind != ArgLiteral || steps[0].Args[0].Value != "9" {
+		t.Errorf("wrong args of first step: %#v", steps[0].Args)
+	}
+
+	tees := steps[1].Tees
+	if len(tees) != 2 || tees[0].Name != "append" || tees[0].Args[0].Kind != ArgPipe {
+		t.Fatalf("wrong tees of second step: %#v", tees)
+	}
+
+	if tees[1].Kind != StepTeeFallback || len(tees[1].Queues) != 1 || tees[1].Queues[0] != sub {
+		t.Errorf("wrong TeeAndFallback: %#v", tees[1])
+	}
+
+	call := steps[2].Args[0]
+	if call.Kind != ArgCall || call.Call.TypeString() != "func() string" {
+		t.Errorf("wrong call argument: %#v", call)
+	}
+
+	if steps[3].Kind != StepSub || steps[3].Type != nil || len(steps[3].Queues) != 1 {
+		t.Errorf("wrong sub step: %#v", steps[3])
+	}
+
+	kinds := []ArgKind{ArgRun, ArgFallback, ArgRace, ArgContext}
+	for i, k := range kinds {
+		if steps[4].Args[i].Kind != k {
+			t.Errorf("args[%d] of last step should be %s, but is %s", i, k, steps[4].Args[i].Kind)
+		}
+	}
+
+	if len(steps[4].Args[1].Queues) != 2 {
+		t.Errorf("Fallback should have 2 queues, but has %d", len(steps[4].Args[1].Queues))
+	}
+}
+
+func TestInsertReplaceRemove(t *testing.T) {
+	template := AddNamed("set", set, "a").
+		AddNamed("append", appendString, "b").
+		TeeNamed("teed", appendString, "t").
+		AddNamed("last", appendString, "c")
+
+	q := template.Clone()
+
+	if err := q.InsertBefore("append", appendString, "x"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := q.Replace("last", appendString, "z"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result = ""
+	if err := q.Run(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if result != "axbtz" {
+		t.Errorf("result should be %#v, but is %#v", "axbtz", result)
+	}
+
+	if err := q.Remove("append"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result = ""
+	q.Run()
+
+	if result != "axz" {
+		t.Errorf("result should be %#v, but is %#v", "axz", result)
+	}
+
+	// the tee was removed with its call
+	err := q.Remove("teed")
+	if _, ok := err.(StepNotFound); !ok {
+		t.Errorf("expecting StepNotFound error, but got %T", err)
+	}
+
+	// the template is not changed
+	result = ""
+	template.Run()
+
+	if result != "abtc" {
+		t.Errorf("result should be %#v, but is %#v", "abtc", result)
+	}
+}
+
+func TestCloneNested(t *testing.T) {
+	sub := AddNamed("inner", appendString, "i")
+	template := Add(set, "a").Sub(sub).TeeAndRun(sub)
+
+	q := template.Clone()
+	steps := q.Steps()
+
+	if steps[1].Queues[0] == sub || steps[1].Tees[0].Queues[0] == sub {
+		t.Fatalf("nested queues should be cloned")
+	}
+
+	steps[1].Queues[0].Replace("inner", appendString, "x")
+	steps[1].Tees[0].Queues[0].Replace("inner", appendString, "y")
+
+	result = ""
+	q.Run()
+	if result != "axy" {
+		t.Errorf("result should be %#v, but is %#v", "axy", result)
+	}
+
+	result = ""
+	template.Run()
+	if result != "aii" {
+		t.Errorf("result should be %#v, but is %#v", "aii", result)
+	}
+}
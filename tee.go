@@ -1,6 +1,9 @@
 package queue
 
-import "reflect"
+import (
+	"context"
+	"reflect"
+)
 
 // Tee allows piping of the same return value to different function calls.
 //
@@ -36,10 +39,40 @@ func (q *Queue) TeeNamed(name string, function interface{}, arguments ...interfa
 	return q
 }
 
-// runTees runs the tees at position pos with the given vals
-func (q *Queue) runTees(pos int, vals []reflect.Value) error {
+// TeeWithHandler behaves like Tee, but sets the given error handler for the tee.
+// Errors of the tee are passed to it instead of the error handler of the queue
+// (see OnErrorAt()).
+func (q *Queue) TeeWithHandler(handler ErrHandler, function interface{}, arguments ...interface{}) *Queue {
+	q.tees[len(q.calls)-1] = append(q.tees[len(q.calls)-1], &call{
+		function:   reflect.ValueOf(function),
+		arguments:  arguments,
+		errHandler: handler,
+	})
+	return q
+}
+
+// runTees runs the tees at position pos with the given vals and
+// returns the first error that is not catched by the error handlers
+func (q *Queue) runTees(ctx context.Context, pos int, vals []reflect.Value, errHandler ErrHandler) error {
 	for i, tee := range q.tees[pos] {
-		_, err := q.pipeFn(tee, pos*100+i, vals)
+		returns, err := q.pipeFn(stepAt(stepAt(ctx, "", pos), "tee", i), tee, pos*100+i, vals)
+		// an aborted run is stopped, regardless of the error handlers
+		if ab := debugAborted(ctx); ab != nil {
+			return ab
+		}
+		if _, halted := isHalt(err, vals); halted {
+			return err
+		}
+		// the returned values of tees are discarded
+		ac := runAutoCloser(ctx)
+		ac.track(returns, vals)
+		closeErrs := ac.release(returns, vals)
+		if err == nil {
+			err = q.handleCloseErrors(errHandler, closeErrs)
+		}
+		if err != nil {
+			err = q.handleError(tee, errHandler, err, "ET")
+		}
 		if err != nil {
 			return err
 		}
@@ -47,11 +80,68 @@ func (q *Queue) runTees(pos int, vals []reflect.Value) error {
 	return nil
 }
 
-func (q *Queue) defaultErrHandler() ErrHandler {
-	if q.errHandler != nil {
-		return q.errHandler
+// feedKind defines how the queues of a tee are run
+type feedKind int
+
+const (
+	feedRun feedKind = iota + 1
+	feedFallback
+	feedCheckRun
+	feedCheckFallback
+)
+
+// teeFeeded adds a tee that runs the given queues with the piped values
+func (q *Queue) teeFeeded(kind feedKind, feededQs []Queuer) *Queue {
+	q.tees[len(q.calls)-1] = append(q.tees[len(q.calls)-1], &call{
+		function:  reflect.ValueOf((func(...interface{}) error)(nil)),
+		arguments: []interface{}{PIPE},
+		feeded:    feededQs,
+		feed:      kind,
+	})
+	return q
+}
+
+// runFeeded runs the queues of the tee c with the given args
+func (q *Queue) runFeeded(ctx context.Context, c *call, args []interface{}) (err error) {
+	if c.feed == feedCheckRun || c.feed == feedCheckFallback {
+		for _, qe := range c.feeded {
+			_, err = (&checker{conv: runConverter(ctx)}).queue(qe.Queue(), "", toTypes(args), nil)
+			if err != nil {
+				return err
+			}
+		}
 	}
-	return STOP
+
+	if c.feed == feedRun || c.feed == feedCheckRun {
+		for k, qe := range c.feeded {
+			err = qe.Queue().run(stepAt(ctx, "teeQueue", k), toValues(args))
+			if ab := debugAborted(ctx); ab != nil {
+				return ab
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errHandler := q.runErrHandler(ctx)
+	for k, qe := range c.feeded {
+		err = qe.Queue().run(stepAt(ctx, "teeQueue", k), toValues(args))
+		if ab := debugAborted(ctx); ab != nil {
+			return ab
+		}
+		if err == nil {
+			return
+		}
+	}
+
+	if err != nil {
+		err2 := errHandler.HandleError(err)
+		q.logDebug("[E] %T(%#v) => %#v", errHandler, err, err2)
+		err = err2
+	}
+	return
 }
 
 // TeeAndRun allows piping of the same return value to different queues.
@@ -65,39 +155,11 @@ func (q *Queue) defaultErrHandler() ErrHandler {
 //
 // To be chainable, TeeAndRun returns the main queue.
 func (q *Queue) TeeAndRun(feededQs ...Queuer) *Queue {
-	fn := func(args ...interface{}) error {
-		for _, feeded := range feededQs {
-			err := feeded.Queue().run(toValues(args))
-			if err != nil {
-				return err
-			}
-		}
-		return nil
-	}
-	q.Tee(fn, PIPE)
-	return q
+	return q.teeFeeded(feedRun, feededQs)
 }
 
 // TeeAndFallback works like TeeAndRun but runs the target queues via Fallback().
 // The position returned by the particular Fallback() call on the target queue is discarded.
 func (q *Queue) TeeAndFallback(feededQs ...Queuer) *Queue {
-
-	fn := func(args ...interface{}) (err error) {
-		errHandler := q.defaultErrHandler()
-		for _, qe := range feededQs {
-			err = qe.Queue().run(toValues(args))
-			if err == nil {
-				return
-			}
-		}
-
-		if err != nil {
-			err2 := errHandler.HandleError(err)
-			q.logDebug("[E] %T(%#v) => %#v", errHandler, err, err2)
-			err = err2
-		}
-		return
-	}
-	q.Tee(fn, PIPE)
-	return q
+	return q.teeFeeded(feedFallback, feededQs)
 }
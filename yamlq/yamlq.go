@@ -0,0 +1,100 @@
+// Package yamlq loads and marshals declarative queue definitions (see queue.QueueDef) in YAML.
+//
+// The YAML document has the same structure as the JSON one, e.g.
+//
+//	onError: IGNORE
+//	steps:
+//	  - func: get
+//	    args: [Age]
+//	  - func: atoi
+//	    args: [{pipe: true}]
+//	  - func: setAge
+//	    args: [{pipe: true}]
+package yamlq
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/go-on/queue.v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the queue of the YAML definition with the functions and error handlers of r
+// (see queue.Registry.Load())
+func Load(r *queue.Registry, data []byte) (*queue.Queue, error) {
+	var def queue.QueueDef
+	if err := Unmarshal(data, &def); err != nil {
+		return nil, err
+	}
+	return r.Load(&def)
+}
+
+// Unmarshal decodes the YAML definition into def
+func Unmarshal(data []byte, def *queue.QueueDef) error {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	js, err := json.Marshal(jsonable(doc))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(js, def)
+}
+
+// Marshal returns the YAML definition of the queue q (see queue.Registry.Marshal())
+func Marshal(r *queue.Registry, q *queue.Queue) ([]byte, error) {
+	js, err := r.MarshalIndent(q)
+	if err != nil {
+		return nil, err
+	}
+	// decoding the JSON into a node keeps the order of the keys
+	var node yaml.Node
+	if err := yaml.Unmarshal(js, &node); err != nil {
+		return nil, err
+	}
+	clearStyle(&node)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonable converts the maps of a decoded YAML document, so that they can be marshalled to JSON
+func jsonable(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, val := range x {
+			x[k] = jsonable(val)
+		}
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, val := range x {
+			m[fmt.Sprint(k)] = jsonable(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range x {
+			x[i] = jsonable(val)
+		}
+	}
+	return v
+}
+
+// clearStyle resets the styles of the JSON, so that the YAML is written in block style
+// and strings are only quoted if needed
+func clearStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		clearStyle(c)
+	}
+}
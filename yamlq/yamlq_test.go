@@ -0,0 +1,57 @@
+package yamlq
+
+import (
+	"strconv"
+	"testing"
+
+	"gopkg.in/go-on/queue.v2"
+)
+
+type person struct{ Age int }
+
+func (p *person) SetAge(age int) { p.Age = age }
+
+const definition = `name: age
+onError: STOP
+steps:
+  - func: atoi
+    args:
+      - "42"
+  - func: setAge
+    args:
+      - pipe: true
+`
+
+func TestLoadAndMarshal(t *testing.T) {
+	p := &person{}
+	r := queue.NewRegistry().Register("atoi", strconv.Atoi).Register("setAge", p.SetAge)
+
+	q, err := Load(r, []byte(definition))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := q.Run(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.Age != 42 {
+		t.Errorf("age should be 42, but is %d", p.Age)
+	}
+
+	data, err := Marshal(r, q)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(data) != definition {
+		t.Errorf("marshalled definition should be\n%s\nbut is\n%s", definition, data)
+	}
+}
+
+func TestLoadError(t *testing.T) {
+	r := queue.NewRegistry()
+	if _, err := Load(r, []byte("steps: [{func: unknown}]")); err == nil {
+		t.Errorf("expecting error for unknown func")
+	}
+	if _, err := Load(r, []byte("steps: [")); err == nil {
+		t.Errorf("expecting error for invalid YAML")
+	}
+}